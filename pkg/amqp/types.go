@@ -452,8 +452,15 @@ func (amqp *AMQP10) MarshalBinary() ([]byte, error) {
 	return amqp.message.MarshalBinary()
 }
 
+// UnmarshalBinary decodes the message, the properties are copied so MarshalBinary encodes them again
 func (amqp *AMQP10) UnmarshalBinary(data []byte) error {
-	return amqp.message.UnmarshalBinary(data)
+	if err := amqp.message.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	amqp.Properties = amqp.message.Properties
+	amqp.ApplicationProperties = amqp.message.ApplicationProperties
+	amqp.Annotations = amqp.message.Annotations
+	return nil
 }
 
 func (amqp *AMQP10) GetData() [][]byte {
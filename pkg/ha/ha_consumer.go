@@ -3,11 +3,9 @@ package ha
 import (
 	"fmt"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
-	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
 	"strings"
 	"sync"
-	"time"
 )
 
 // ReliableConsumer is a consumer that can reconnect in case of connection problems
@@ -22,6 +20,7 @@ type ReliableConsumer struct {
 	status          int
 	messagesHandler stream.MessagesHandler
 	currentPosition int64 // the last offset consumed. It is needed in case of restart
+	backoffPolicy   stream.BackoffPolicy
 
 	//bootstrap: if true the consumer will start from the user offset.
 	// If false it will start from the last offset consumed (currentPosition)
@@ -32,12 +31,11 @@ func (c *ReliableConsumer) handleNotifyClose(channelClose stream.ChannelClose) {
 	go func() {
 		for event := range channelClose {
 			if strings.EqualFold(event.Reason, stream.SocketClosed) || strings.EqualFold(event.Reason, stream.MetaDataUpdate) {
-				logs.LogWarn("[Reliable] - %s closed unexpectedly.. Reconnecting..", c.getInfo())
+				c.env.GetLogger().Warn("[Reliable] - Closed unexpectedly.. Reconnecting..", "info", c.getInfo())
 				c.bootstrap = false
-				err, reconnected := retry(0, c)
+				err, reconnected := retry(c)
 				if err != nil {
-					logs.LogInfo(""+
-						"[Reliable] - %s won't be reconnected. Error: %s", c.getInfo(), err)
+					c.env.GetLogger().Info("[Reliable] - Won't be reconnected", "info", c.getInfo(), "error", err)
 				}
 				if reconnected {
 					c.setStatus(StatusOpen)
@@ -46,7 +44,7 @@ func (c *ReliableConsumer) handleNotifyClose(channelClose stream.ChannelClose) {
 				}
 
 			} else {
-				logs.LogInfo("[Reliable] - %s closed normally. Reason: %s", c.getInfo(), event.Reason)
+				c.env.GetLogger().Info("[Reliable] - Closed normally", "info", c.getInfo(), "reason", event.Reason)
 				c.setStatus(StatusClosed)
 			}
 		}
@@ -108,8 +106,22 @@ func (c *ReliableConsumer) getInfo() string {
 		c.consumerOptions.ClientProvidedName, c.streamName)
 }
 
-func (c *ReliableConsumer) getTimeOut() time.Duration {
-	return time.Duration(3)
+func (c *ReliableConsumer) getBackoffPolicy() stream.BackoffPolicy {
+	c.mutexStatus.Lock()
+	defer c.mutexStatus.Unlock()
+	if c.backoffPolicy != nil {
+		return c.backoffPolicy
+	}
+	return c.env.GetBackoffPolicy()
+}
+
+// SetBackoffPolicy sets the delay between the reconnection attempts.
+// By default the consumer uses the BackoffPolicy of the environment
+func (c *ReliableConsumer) SetBackoffPolicy(policy stream.BackoffPolicy) *ReliableConsumer {
+	c.mutexStatus.Lock()
+	defer c.mutexStatus.Unlock()
+	c.backoffPolicy = policy
+	return c
 }
 
 func (c *ReliableConsumer) newConsumer() error {
@@ -119,8 +131,8 @@ func (c *ReliableConsumer) newConsumer() error {
 	if c.bootstrap {
 		offset = c.consumerOptions.Offset
 	}
-	logs.LogDebug("[Reliable] - creating %s. Boot: %s. StartOffset: %s", c.getInfo(),
-		c.bootstrap, offset)
+	c.env.GetLogger().Debug("[Reliable] - Creating the consumer", "info", c.getInfo(),
+		"boot", c.bootstrap, "start_offset", offset)
 	consumer, err := c.env.NewConsumer(c.streamName, func(consumerContext stream.ConsumerContext, message *amqp.Message) {
 		c.mutexConnection.Lock()
 
@@ -8,7 +8,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/message"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
 )
@@ -26,11 +25,10 @@ func (p *ReliableProducer) handleNotifyClose(channelClose stream.ChannelClose) {
 	go func() {
 		for event := range channelClose {
 			if strings.EqualFold(event.Reason, stream.SocketClosed) || strings.EqualFold(event.Reason, stream.MetaDataUpdate) {
-				logs.LogWarn("[Reliable] - %s closed unexpectedly.. Reconnecting..", p.getInfo())
-				err, reconnected := retry(0, p)
+				p.env.GetLogger().Warn("[Reliable] - Closed unexpectedly.. Reconnecting..", "info", p.getInfo())
+				err, reconnected := retry(p)
 				if err != nil {
-					logs.LogInfo(""+
-						"[Reliable] - %s won't be reconnected. Error: %s", p.getInfo(), err)
+					p.env.GetLogger().Info("[Reliable] - Won't be reconnected", "info", p.getInfo(), "error", err)
 				}
 				if reconnected {
 					p.setStatus(StatusOpen)
@@ -38,7 +36,7 @@ func (p *ReliableProducer) handleNotifyClose(channelClose stream.ChannelClose) {
 					p.setStatus(StatusClosed)
 				}
 			} else {
-				logs.LogInfo("[Reliable] - %s closed normally. Reason: %s", p.getInfo(), event.Reason)
+				p.env.GetLogger().Info("[Reliable] - Closed normally", "info", p.getInfo(), "reason", event.Reason)
 				p.setStatus(StatusClosed)
 			}
 
@@ -64,6 +62,7 @@ type ReliableProducer struct {
 	mutexStatus           *sync.Mutex
 	status                int
 	reconnectionSignal    *sync.Cond
+	backoffPolicy         stream.BackoffPolicy
 }
 
 type ConfirmMessageHandler func(messageConfirm []*stream.ConfirmationStatus)
@@ -119,11 +118,11 @@ func (p *ReliableProducer) isReadyToSend() error {
 	}
 
 	if p.GetStatus() == StatusReconnecting {
-		logs.LogDebug("[Reliable] %s is reconnecting. The send is blocked", p.getInfo())
+		p.env.GetLogger().Debug("[Reliable] - Reconnecting. The send is blocked", "info", p.getInfo())
 		p.reconnectionSignal.L.Lock()
 		p.reconnectionSignal.Wait()
 		p.reconnectionSignal.L.Unlock()
-		logs.LogDebug("[Reliable] %s reconnected. The send is unlocked", p.getInfo())
+		p.env.GetLogger().Debug("[Reliable] - Reconnected. The send is unlocked", "info", p.getInfo())
 	}
 
 	return nil
@@ -138,7 +137,7 @@ func (p *ReliableProducer) checkWriteError(errW error) error {
 			}
 		default:
 			time.Sleep(500 * time.Millisecond)
-			logs.LogError("[Reliable] %s - error during send %s", p.getInfo(), errW.Error())
+			p.env.GetLogger().Error("[Reliable] - Error during send", "info", p.getInfo(), "error", errW)
 		}
 	}
 	return nil
@@ -200,8 +199,13 @@ func (p *ReliableProducer) getNewInstance() newEntityInstance {
 	return p.newProducer
 }
 
-func (p *ReliableProducer) getTimeOut() time.Duration {
-	return p.producerOptions.ConfirmationTimeOut
+func (p *ReliableProducer) getBackoffPolicy() stream.BackoffPolicy {
+	p.mutexStatus.Lock()
+	defer p.mutexStatus.Unlock()
+	if p.backoffPolicy != nil {
+		return p.backoffPolicy
+	}
+	return p.env.GetBackoffPolicy()
 }
 
 func (p *ReliableProducer) getStreamName() string {
@@ -210,6 +214,15 @@ func (p *ReliableProducer) getStreamName() string {
 
 // End of IReliable interface
 
+// SetBackoffPolicy sets the delay between the reconnection attempts.
+// By default the producer uses the BackoffPolicy of the environment
+func (p *ReliableProducer) SetBackoffPolicy(policy stream.BackoffPolicy) *ReliableProducer {
+	p.mutexStatus.Lock()
+	defer p.mutexStatus.Unlock()
+	p.backoffPolicy = policy
+	return p
+}
+
 func (p *ReliableProducer) GetBroker() *stream.Broker {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
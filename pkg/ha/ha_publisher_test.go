@@ -1,6 +1,7 @@
 package ha
 
 import (
+	"errors"
 	"sync/atomic"
 	"time"
 
@@ -199,4 +200,66 @@ var _ = Describe("Reliable Producer", func() {
 
 	})
 
+	It("Reliable Producer backoff policy", func() {
+		producer, err := NewReliableProducer(envForRProducer,
+			streamForRProducer, NewProducerOptions(), func(messageConfirm []*ConfirmationStatus) {
+			})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(producer.getBackoffPolicy()).To(Equal(envForRProducer.GetBackoffPolicy()))
+		policy := NewConstantBackoff(10 * time.Millisecond)
+		producer.SetBackoffPolicy(policy)
+		Expect(producer.getBackoffPolicy()).To(Equal(policy))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+	})
+
+	It("Retry returns ErrRetriesExhausted", func() {
+		reliable := &failingReliable{env: envForRProducer, streamName: streamForRProducer,
+			policy: NewMaxAttemptsBackoff(NewConstantBackoff(10*time.Millisecond), 3)}
+		err, reconnected := retry(reliable)
+		Expect(reconnected).To(BeFalse())
+		Expect(err).To(MatchError(ErrRetriesExhausted))
+		Expect(atomic.LoadInt32(&reliable.attempts)).To(Equal(int32(3)))
+		Expect(reliable.GetStatus()).To(Equal(StatusReconnecting))
+	})
+
 })
+
+// failingReliable is an IReliable that can't create the new instance
+type failingReliable struct {
+	env        *Environment
+	streamName string
+	policy     BackoffPolicy
+	attempts   int32
+	status     int32
+}
+
+func (f *failingReliable) setStatus(value int) {
+	atomic.StoreInt32(&f.status, int32(value))
+}
+
+func (f *failingReliable) GetStatus() int {
+	return int(atomic.LoadInt32(&f.status))
+}
+
+func (f *failingReliable) getInfo() string {
+	return "failing reliable"
+}
+
+func (f *failingReliable) getEnv() *Environment {
+	return f.env
+}
+
+func (f *failingReliable) getNewInstance() newEntityInstance {
+	return func() error {
+		atomic.AddInt32(&f.attempts, 1)
+		return errors.New("can't create the instance")
+	}
+}
+
+func (f *failingReliable) getBackoffPolicy() BackoffPolicy {
+	return f.policy
+}
+
+func (f *failingReliable) getStreamName() string {
+	return f.streamName
+}
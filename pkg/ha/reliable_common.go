@@ -1,10 +1,10 @@
 package ha
 
 import (
+	"context"
 	"errors"
-	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
+	"fmt"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
-	"math/rand"
 	"time"
 )
 
@@ -15,6 +15,10 @@ const (
 	StatusReconnecting       = 4
 )
 
+// streamMetaDataTimeout bounds the metadata call of each attempt, so the locator connection
+// of the environment is not retried with the environment BackoffPolicy
+const streamMetaDataTimeout = 10 * time.Second
+
 type newEntityInstance func() error
 
 type IReliable interface {
@@ -23,16 +27,18 @@ type IReliable interface {
 	getInfo() string
 	getEnv() *stream.Environment
 	getNewInstance() newEntityInstance
-	getTimeOut() time.Duration
+	getBackoffPolicy() stream.BackoffPolicy
 	getStreamName() string
 }
 
 // Retry is a function that retries the IReliable to the stream
 // The first step is to set the status to reconnecting
-// Then it sleeps for a random time between 2 and the timeout to avoid overlapping with other reconnecting
-// Then it checks if the stream exists. During the restart the stream could be deleted
+// Then for each attempt it sleeps for the delay of the BackoffPolicy. The default policy has a random jitter to avoid overlapping with other reconnecting
+// Then it checks if the stream exists. During the restart the stream could be deleted.
+// The check is bounded by streamMetaDataTimeout, a failed check is retried by the BackoffPolicy of the IReliable
 // If the stream does not exist it returns a StreamDoesNotExist error
 // If the stream exists it tries to create a new instance of the IReliable
+// When the BackoffPolicy has no more attempts it returns an ErrRetriesExhausted error
 
 //
 // The stream could be in a `StreamNotAvailable` status or the `LeaderNotReady`
@@ -40,39 +46,60 @@ type IReliable interface {
 // `LeaderNotReady` is a client side error: Stream exists it is Ready but the leader is not elected yet. It is mandatory for the Producer
 // In both cases it retries the reconnection
 
-func retry(backoff int, reliable IReliable) (error, bool) {
+func retry(reliable IReliable) (error, bool) {
 	reliable.setStatus(StatusReconnecting)
-	sleepValue := rand.Intn(int((reliable.getTimeOut().Seconds()-2+1)+2)*1000) + backoff*1000
-	logs.LogInfo("[Reliable] - The %s for the stream %s is in reconnection in %d milliseconds", reliable.getInfo(), reliable.getStreamName(), sleepValue)
-	time.Sleep(time.Duration(sleepValue) * time.Millisecond)
-	streamMetaData, errS := reliable.getEnv().StreamMetaData(reliable.getStreamName())
-	if errors.Is(errS, stream.StreamDoesNotExist) {
-		return errS, false
-	}
-	if errors.Is(errS, stream.StreamNotAvailable) {
-		logs.LogInfo("[Reliable] - The stream %s is not available for %s. Trying to reconnect", reliable.getStreamName(), reliable.getInfo())
-		return retry(backoff+1, reliable)
-	}
-	if errors.Is(errS, stream.LeaderNotReady) {
-		logs.LogInfo("[Reliable] - The leader for the stream %s is not ready for %s. Trying to reconnect", reliable.getStreamName(), reliable.getInfo())
-		return retry(backoff+1, reliable)
-	}
+	logger := reliable.getEnv().GetLogger()
+	var lastError error
+	for attempt := 1; ; attempt++ {
+		sleepValue, canRetry := reliable.getBackoffPolicy().NextDelay(attempt)
+		if !canRetry {
+			return fmt.Errorf("%w: %s not reconnected after %d attempts, last error: %v",
+				stream.ErrRetriesExhausted, reliable.getInfo(), attempt-1, lastError), false
+		}
+		logger.Info("[Reliable] - In reconnection", "info", reliable.getInfo(), "stream", reliable.getStreamName(),
+			"retry_in", sleepValue, "attempt", attempt)
+		time.Sleep(sleepValue)
+		ctx, cancel := context.WithTimeout(context.Background(), streamMetaDataTimeout)
+		streamMetaData, errS := reliable.getEnv().StreamMetaDataCtx(ctx, reliable.getStreamName())
+		cancel()
+		if errors.Is(errS, stream.StreamDoesNotExist) {
+			return errS, false
+		}
+		if errors.Is(errS, stream.StreamNotAvailable) {
+			logger.Info("[Reliable] - The stream is not available. Trying to reconnect", "info", reliable.getInfo(),
+				"stream", reliable.getStreamName())
+			lastError = errS
+			continue
+		}
+		if errors.Is(errS, stream.LeaderNotReady) {
+			logger.Info("[Reliable] - The leader for the stream is not ready. Trying to reconnect", "info", reliable.getInfo(),
+				"stream", reliable.getStreamName())
+			lastError = errS
+			continue
+		}
 
-	var result error
-	if streamMetaData != nil {
-		logs.LogInfo("[Reliable] - The stream %s exists. Reconnecting the %s.", reliable.getStreamName(), reliable.getInfo())
-		result = reliable.getNewInstance()()
-		if result == nil {
-			logs.LogInfo("[Reliable] - The stream %s exists. %s reconnected.", reliable.getInfo(), reliable.getStreamName())
-		} else {
-			logs.LogInfo("[Reliable] - error %s creating %s for the stream %s. Trying to reconnect", result, reliable.getInfo(), reliable.getStreamName())
-			return retry(backoff+1, reliable)
+		if errS != nil {
+			logger.Info("[Reliable] - Can't check the stream. Trying to reconnect", "info", reliable.getInfo(),
+				"stream", reliable.getStreamName(), "error", errS)
+			lastError = errS
+			continue
 		}
-	} else {
-		logs.LogError("[Reliable] - The stream %s does not exist for %s. Closing..", reliable.getStreamName(), reliable.getInfo())
-		return stream.StreamDoesNotExist, false
-	}
 
-	return result, true
+		if streamMetaData == nil {
+			logger.Error("[Reliable] - The stream does not exist. Closing..", "info", reliable.getInfo(),
+				"stream", reliable.getStreamName())
+			return stream.StreamDoesNotExist, false
+		}
 
+		logger.Info("[Reliable] - The stream exists. Reconnecting", "info", reliable.getInfo(), "stream", reliable.getStreamName())
+		result := reliable.getNewInstance()()
+		reliable.getEnv().GetMetricsCollector().Reconnection(reliable.getInfo(), result)
+		if result == nil {
+			logger.Info("[Reliable] - Reconnected", "info", reliable.getInfo(), "stream", reliable.getStreamName())
+			return nil, true
+		}
+		logger.Info("[Reliable] - Error during the creation. Trying to reconnect", "info", reliable.getInfo(),
+			"stream", reliable.getStreamName(), "error", result)
+		lastError = result
+	}
 }
@@ -3,9 +3,12 @@ package logs
 import (
 	"fmt"
 	"log"
+	"strings"
 )
 
 const (
+	ERROR = -2
+	WARN  = -1
 	INFO  = 0
 	DEBUG = 1
 )
@@ -13,7 +16,9 @@ const (
 var LogLevel int8
 
 func LogInfo(message string, v ...interface{}) {
-	log.Printf(fmt.Sprintf("[info] - %s", message), v...)
+	if LogLevel >= INFO {
+		log.Printf(fmt.Sprintf("[info] - %s", message), v...)
+	}
 }
 
 func LogError(message string, v ...interface{}) {
@@ -27,5 +32,65 @@ func LogDebug(message string, v ...interface{}) {
 }
 
 func LogWarn(message string, v ...interface{}) {
-	log.Printf(fmt.Sprintf("[warn] - %s", message), v...)
+	if LogLevel >= WARN {
+		log.Printf(fmt.Sprintf("[warn] - %s", message), v...)
+	}
+}
+
+// Logger is the structured logger used by the client.
+// keysAndValues are pairs of key (string) and value, for example:
+//
+//	logger.Warn("error during send", "stream", streamName, "producer_id", id, "error", err)
+type Logger interface {
+	Debug(message string, keysAndValues ...interface{})
+	Info(message string, keysAndValues ...interface{})
+	Warn(message string, keysAndValues ...interface{})
+	Error(message string, keysAndValues ...interface{})
+}
+
+// NewStandardLogger returns the default Logger.
+// It writes with the standard log package, filtered by the global LogLevel, in the format:
+//
+//	[warn] - message key=value key2=value2
+func NewStandardLogger() Logger {
+	return &standardLogger{}
+}
+
+type standardLogger struct {
+}
+
+func (s *standardLogger) Debug(message string, keysAndValues ...interface{}) {
+	if LogLevel >= DEBUG {
+		log.Print("[debug] - " + formatKeysAndValues(message, keysAndValues))
+	}
+}
+
+func (s *standardLogger) Info(message string, keysAndValues ...interface{}) {
+	if LogLevel >= INFO {
+		log.Print("[info] - " + formatKeysAndValues(message, keysAndValues))
+	}
+}
+
+func (s *standardLogger) Warn(message string, keysAndValues ...interface{}) {
+	if LogLevel >= WARN {
+		log.Print("[warn] - " + formatKeysAndValues(message, keysAndValues))
+	}
+}
+
+func (s *standardLogger) Error(message string, keysAndValues ...interface{}) {
+	log.Print("[error] - " + formatKeysAndValues(message, keysAndValues))
+}
+
+func formatKeysAndValues(message string, keysAndValues []interface{}) string {
+	var builder strings.Builder
+	builder.WriteString(message)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		builder.WriteString(" ")
+		if i+1 == len(keysAndValues) {
+			builder.WriteString(fmt.Sprintf("!BADKEY=%v", keysAndValues[i]))
+			break
+		}
+		builder.WriteString(fmt.Sprintf("%v=%v", keysAndValues[i], keysAndValues[i+1]))
+	}
+	return builder.String()
 }
@@ -0,0 +1,34 @@
+//go:build go1.21
+
+package logs
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger adapts a log/slog logger to the Logger interface.
+// The levels and the handler (text, JSON...) are defined by the slog logger
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return &slogLogger{logger: logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (s *slogLogger) Debug(message string, keysAndValues ...interface{}) {
+	s.logger.Log(context.Background(), slog.LevelDebug, message, keysAndValues...)
+}
+
+func (s *slogLogger) Info(message string, keysAndValues ...interface{}) {
+	s.logger.Log(context.Background(), slog.LevelInfo, message, keysAndValues...)
+}
+
+func (s *slogLogger) Warn(message string, keysAndValues ...interface{}) {
+	s.logger.Log(context.Background(), slog.LevelWarn, message, keysAndValues...)
+}
+
+func (s *slogLogger) Error(message string, keysAndValues ...interface{}) {
+	s.logger.Log(context.Background(), slog.LevelError, message, keysAndValues...)
+}
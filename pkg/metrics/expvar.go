@@ -0,0 +1,12 @@
+package metrics
+
+import "expvar"
+
+// PublishExpvar exports the snapshot of the collector as the expvar variable name,
+// available as JSON on /debug/vars when the expvar handler is served.
+// Like expvar.Publish, it panics if the name is already registered
+func PublishExpvar(name string, collector *InMemoryCollector) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return collector.Snapshot()
+	}))
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
+)
+
+// Snapshot is a point-in-time copy of the metrics collected by InMemoryCollector
+type Snapshot struct {
+	PublishedMessages   int64            `json:"published_messages"`
+	PublishedBytes      int64            `json:"published_bytes"`
+	ConfirmedMessages   int64            `json:"confirmed_messages"`
+	ConfirmLatencyAvg   time.Duration    `json:"confirm_latency_avg"`
+	ConfirmLatencyMax   time.Duration    `json:"confirm_latency_max"`
+	PublishErrors       int64            `json:"publish_errors"`
+	PublishErrorsByCode map[uint16]int64 `json:"publish_errors_by_code"`
+	// PendingMessages and UnConfirmedMessages are the queue depth summed across the producers
+	PendingMessages     int64 `json:"pending_messages"`
+	UnConfirmedMessages int64 `json:"unconfirmed_messages"`
+
+	DeliveredChunks   int64 `json:"delivered_chunks"`
+	DeliveredMessages int64 `json:"delivered_messages"`
+	DeliveredBytes    int64 `json:"delivered_bytes"`
+	// CreditsOutstanding is the number of credits granted to the broker and not yet used by a chunk
+	CreditsOutstanding int64 `json:"credits_outstanding"`
+
+	HeartbeatsReceived   int64 `json:"heartbeats_received"`
+	HeartbeatsMissed     int64 `json:"heartbeats_missed"`
+	Reconnections        int64 `json:"reconnections"`
+	ReconnectionFailures int64 `json:"reconnection_failures"`
+}
+
+// queueDepth is updated with atomic operations, ProducerQueueDepth is called on each send and confirmation
+type queueDepth struct {
+	pending     int64
+	unConfirmed int64
+}
+
+// InMemoryCollector is a stream.MetricsCollector that keeps the counters in memory.
+// Use Snapshot to read them, or PublishExpvar to export them
+type InMemoryCollector struct {
+	publishedMessages    int64
+	publishedBytes       int64
+	confirmedMessages    int64
+	confirmLatencyTotal  int64
+	confirmLatencyMax    int64
+	publishErrors        int64
+	deliveredChunks      int64
+	deliveredMessages    int64
+	deliveredBytes       int64
+	heartbeatsReceived   int64
+	heartbeatsMissed     int64
+	reconnections        int64
+	reconnectionFailures int64
+
+	mutex               *sync.Mutex
+	publishErrorsByCode map[uint16]int64
+	queueDepths         *sync.Map // *stream.Producer -> *queueDepth
+	credits             *sync.Map // *stream.Consumer -> *int64, the credits not used by a chunk
+}
+
+var _ stream.MetricsCollector = &InMemoryCollector{}
+
+func NewInMemoryCollector() *InMemoryCollector {
+	return &InMemoryCollector{
+		mutex:               &sync.Mutex{},
+		publishErrorsByCode: map[uint16]int64{},
+		queueDepths:         &sync.Map{},
+		credits:             &sync.Map{},
+	}
+}
+
+func (c *InMemoryCollector) MessagePublished(_ *stream.Producer, sizeBytes int) {
+	atomic.AddInt64(&c.publishedMessages, 1)
+	atomic.AddInt64(&c.publishedBytes, int64(sizeBytes))
+}
+
+func (c *InMemoryCollector) MessageConfirmed(_ *stream.Producer, latency time.Duration) {
+	atomic.AddInt64(&c.confirmedMessages, 1)
+	atomic.AddInt64(&c.confirmLatencyTotal, int64(latency))
+	for {
+		current := atomic.LoadInt64(&c.confirmLatencyMax)
+		if int64(latency) <= current || atomic.CompareAndSwapInt64(&c.confirmLatencyMax, current, int64(latency)) {
+			return
+		}
+	}
+}
+
+func (c *InMemoryCollector) PublishError(_ *stream.Producer, code uint16) {
+	atomic.AddInt64(&c.publishErrors, 1)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.publishErrorsByCode[code]++
+}
+
+func (c *InMemoryCollector) ProducerQueueDepth(producer *stream.Producer, pending int, unConfirmed int) {
+	value, ok := c.queueDepths.Load(producer)
+	if !ok {
+		value, _ = c.queueDepths.LoadOrStore(producer, &queueDepth{})
+	}
+	depth := value.(*queueDepth)
+	atomic.StoreInt64(&depth.pending, int64(pending))
+	atomic.StoreInt64(&depth.unConfirmed, int64(unConfirmed))
+}
+
+// ProducerClosed removes the queue depth of the producer, the messages not confirmed when
+// the producer is closed are not counted anymore
+func (c *InMemoryCollector) ProducerClosed(producer *stream.Producer) {
+	c.queueDepths.Delete(producer)
+}
+
+func (c *InMemoryCollector) ChunkDelivered(consumer *stream.Consumer, messages int, sizeBytes int) {
+	atomic.AddInt64(&c.deliveredChunks, 1)
+	atomic.AddInt64(&c.deliveredMessages, int64(messages))
+	atomic.AddInt64(&c.deliveredBytes, int64(sizeBytes))
+	// a chunk received after ConsumerClosed is not counted
+	if value, ok := c.credits.Load(consumer); ok {
+		atomic.AddInt64(value.(*int64), -1)
+	}
+}
+
+func (c *InMemoryCollector) CreditGranted(consumer *stream.Consumer, credits int) {
+	value, ok := c.credits.Load(consumer)
+	if !ok {
+		value, _ = c.credits.LoadOrStore(consumer, new(int64))
+	}
+	atomic.AddInt64(value.(*int64), int64(credits))
+}
+
+// ConsumerClosed removes the credits the consumer didn't use
+func (c *InMemoryCollector) ConsumerClosed(consumer *stream.Consumer) {
+	c.credits.Delete(consumer)
+}
+
+func (c *InMemoryCollector) HeartbeatReceived(_ string) {
+	atomic.AddInt64(&c.heartbeatsReceived, 1)
+}
+
+func (c *InMemoryCollector) HeartbeatMissed(_ string) {
+	atomic.AddInt64(&c.heartbeatsMissed, 1)
+}
+
+func (c *InMemoryCollector) Reconnection(_ string, err error) {
+	if err != nil {
+		atomic.AddInt64(&c.reconnectionFailures, 1)
+		return
+	}
+	atomic.AddInt64(&c.reconnections, 1)
+}
+
+// Snapshot returns a copy of the current metrics
+func (c *InMemoryCollector) Snapshot() Snapshot {
+	snapshot := Snapshot{
+		PublishedMessages:    atomic.LoadInt64(&c.publishedMessages),
+		PublishedBytes:       atomic.LoadInt64(&c.publishedBytes),
+		ConfirmedMessages:    atomic.LoadInt64(&c.confirmedMessages),
+		ConfirmLatencyMax:    time.Duration(atomic.LoadInt64(&c.confirmLatencyMax)),
+		PublishErrors:        atomic.LoadInt64(&c.publishErrors),
+		PublishErrorsByCode:  map[uint16]int64{},
+		DeliveredChunks:      atomic.LoadInt64(&c.deliveredChunks),
+		DeliveredMessages:    atomic.LoadInt64(&c.deliveredMessages),
+		DeliveredBytes:       atomic.LoadInt64(&c.deliveredBytes),
+		HeartbeatsReceived:   atomic.LoadInt64(&c.heartbeatsReceived),
+		HeartbeatsMissed:     atomic.LoadInt64(&c.heartbeatsMissed),
+		Reconnections:        atomic.LoadInt64(&c.reconnections),
+		ReconnectionFailures: atomic.LoadInt64(&c.reconnectionFailures),
+	}
+	if snapshot.ConfirmedMessages > 0 {
+		snapshot.ConfirmLatencyAvg = time.Duration(atomic.LoadInt64(&c.confirmLatencyTotal) / snapshot.ConfirmedMessages)
+	}
+
+	c.mutex.Lock()
+	for code, count := range c.publishErrorsByCode {
+		snapshot.PublishErrorsByCode[code] = count
+	}
+	c.mutex.Unlock()
+	c.queueDepths.Range(func(_, value any) bool {
+		depth := value.(*queueDepth)
+		snapshot.PendingMessages += atomic.LoadInt64(&depth.pending)
+		snapshot.UnConfirmedMessages += atomic.LoadInt64(&depth.unConfirmed)
+		return true
+	})
+	c.credits.Range(func(_, value any) bool {
+		snapshot.CreditsOutstanding += atomic.LoadInt64(value.(*int64))
+		return true
+	})
+	return snapshot
+}
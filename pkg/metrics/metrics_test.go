@@ -0,0 +1,89 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/rabbitmq/rabbitmq-stream-go-client/pkg/metrics"
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
+)
+
+var _ = Describe("Metrics", func() {
+
+	It("In memory snapshot", func() {
+		collector := NewInMemoryCollector()
+		producer := &stream.Producer{}
+		secondProducer := &stream.Producer{}
+		consumer := &stream.Consumer{}
+
+		collector.MessagePublished(producer, 10)
+		collector.MessagePublished(producer, 30)
+		collector.MessageConfirmed(producer, 10*time.Millisecond)
+		collector.MessageConfirmed(producer, 30*time.Millisecond)
+		collector.PublishError(producer, 2)
+		collector.PublishError(producer, 2)
+		collector.PublishError(producer, 18)
+		collector.ProducerQueueDepth(producer, 5, 10)
+		collector.ProducerQueueDepth(secondProducer, 1, 2)
+		collector.CreditGranted(consumer, 10)
+		collector.ChunkDelivered(consumer, 100, 2048)
+		collector.CreditGranted(consumer, 1)
+		collector.ChunkDelivered(consumer, 50, 1024)
+		collector.HeartbeatReceived("connection")
+		collector.HeartbeatMissed("connection")
+		collector.Reconnection("producer", errors.New("stream not available"))
+		collector.Reconnection("producer", nil)
+
+		snapshot := collector.Snapshot()
+		Expect(snapshot.PublishedMessages).To(Equal(int64(2)))
+		Expect(snapshot.PublishedBytes).To(Equal(int64(40)))
+		Expect(snapshot.ConfirmedMessages).To(Equal(int64(2)))
+		Expect(snapshot.ConfirmLatencyAvg).To(Equal(20 * time.Millisecond))
+		Expect(snapshot.ConfirmLatencyMax).To(Equal(30 * time.Millisecond))
+		Expect(snapshot.PublishErrors).To(Equal(int64(3)))
+		Expect(snapshot.PublishErrorsByCode).To(Equal(map[uint16]int64{2: 2, 18: 1}))
+		Expect(snapshot.PendingMessages).To(Equal(int64(6)))
+		Expect(snapshot.UnConfirmedMessages).To(Equal(int64(12)))
+		Expect(snapshot.DeliveredChunks).To(Equal(int64(2)))
+		Expect(snapshot.DeliveredMessages).To(Equal(int64(150)))
+		Expect(snapshot.DeliveredBytes).To(Equal(int64(3072)))
+		Expect(snapshot.CreditsOutstanding).To(Equal(int64(9)))
+		Expect(snapshot.HeartbeatsReceived).To(Equal(int64(1)))
+		Expect(snapshot.HeartbeatsMissed).To(Equal(int64(1)))
+		Expect(snapshot.Reconnections).To(Equal(int64(1)))
+		Expect(snapshot.ReconnectionFailures).To(Equal(int64(1)))
+
+		// the closed producers are removed from the queue depth, with their outstanding messages
+		collector.ProducerClosed(producer)
+		snapshot = collector.Snapshot()
+		Expect(snapshot.PendingMessages).To(Equal(int64(1)))
+		Expect(snapshot.UnConfirmedMessages).To(Equal(int64(2)))
+
+		// the credits not used by a closed consumer are not outstanding anymore
+		secondConsumer := &stream.Consumer{}
+		collector.CreditGranted(secondConsumer, 5)
+		Expect(collector.Snapshot().CreditsOutstanding).To(Equal(int64(14)))
+		collector.ConsumerClosed(consumer)
+		collector.ChunkDelivered(consumer, 10, 100)
+		snapshot = collector.Snapshot()
+		Expect(snapshot.CreditsOutstanding).To(Equal(int64(5)))
+		Expect(snapshot.DeliveredChunks).To(Equal(int64(3)))
+	})
+
+	It("Expvar exporter", func() {
+		collector := NewInMemoryCollector()
+		PublishExpvar("rabbitmq_stream_test", collector)
+		collector.MessagePublished(&stream.Producer{}, 10)
+
+		variable := expvar.Get("rabbitmq_stream_test")
+		Expect(variable).NotTo(BeNil())
+		var snapshot Snapshot
+		Expect(json.Unmarshal([]byte(variable.String()), &snapshot)).NotTo(HaveOccurred())
+		Expect(snapshot.PublishedMessages).To(Equal(int64(1)))
+		Expect(snapshot.PublishedBytes).To(Equal(int64(10)))
+	})
+})
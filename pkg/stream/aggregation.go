@@ -69,22 +69,35 @@ type iCompress interface {
 	UnCompress(source *bufio.Reader, dataSize, uncompressedDataSize uint32) *bufio.Reader
 }
 
-func compressByValue(value byte) iCompress {
+func compressByValue(value byte, logger logs.Logger) iCompress {
 
+	codec := compressLogger{logger: logger}
 	switch value {
 	case GZIP:
-		return compressGZIP{}
+		return compressGZIP{codec}
 	case SNAPPY:
-		return compressSnappy{}
+		return compressSnappy{codec}
 	case LZ4:
-		return compressLZ4{}
+		return compressLZ4{codec}
 	case ZSTD:
-		return compressZSTD{}
+		return compressZSTD{codec}
 	}
 
 	return compressNONE{}
 }
 
+// compressLogger is the logger of the client that uses the codec
+type compressLogger struct {
+	logger logs.Logger
+}
+
+func (c compressLogger) log() logs.Logger {
+	if c.logger == nil {
+		return logs.NewStandardLogger()
+	}
+	return c.logger
+}
+
 type compressNONE struct {
 }
 
@@ -106,6 +119,7 @@ func (es compressNONE) UnCompress(source *bufio.Reader, _, _ uint32) *bufio.Read
 }
 
 type compressGZIP struct {
+	compressLogger
 }
 
 func (es compressGZIP) Compress(subEntries *subEntries) {
@@ -137,13 +151,13 @@ func (es compressGZIP) UnCompress(source *bufio.Reader, dataSize, uncompressedDa
 	/// array of compress data
 
 	if err != nil {
-		logs.LogError("GZIP Error during reading buffer %s", err)
+		es.log().Error("GZIP Error during reading buffer", "error", err)
 	}
 
 	reader, err := gzip.NewReader(bytes.NewBuffer(zipperBuffer))
 
 	if err != nil {
-		logs.LogError("Error creating GZIP NewReader  %s", err)
+		es.log().Error("Error creating GZIP NewReader", "error", err)
 	}
 	defer reader.Close()
 	/// headers ---> payload --> headers --> payload (compressed)
@@ -151,7 +165,7 @@ func (es compressGZIP) UnCompress(source *bufio.Reader, dataSize, uncompressedDa
 	// Read in data.
 	uncompressedReader, err := ioutil.ReadAll(reader)
 	if err != nil {
-		logs.LogError("Error during reading buffer %s", err)
+		es.log().Error("Error during reading buffer", "error", err)
 	}
 	if uint32(len(uncompressedReader)) != uncompressedDataSize {
 		panic("uncompressedDataSize != count")
@@ -163,6 +177,7 @@ func (es compressGZIP) UnCompress(source *bufio.Reader, dataSize, uncompressedDa
 }
 
 type compressSnappy struct {
+	compressLogger
 }
 
 func (es compressSnappy) Compress(subEntries *subEntries) {
@@ -172,20 +187,20 @@ func (es compressSnappy) Compress(subEntries *subEntries) {
 		for _, msg := range entry.messages {
 			size := len(msg.messageBytes)
 			if _, err := w.Write(bytesFromInt(uint32(size))); err != nil {
-				logs.LogError("Error compressing with Snappy %v", err)
+				es.log().Error("Error compressing with Snappy", "error", err)
 				//return err  // TODO we should return error if we cannot compress
 			}
 			if _, err := w.Write(msg.messageBytes); err != nil {
-				logs.LogError("Error compressing with Snappy %v", err)
+				es.log().Error("Error compressing with Snappy", "error", err)
 				//return err  // TODO we should return error if we cannot compress
 			}
 		}
 		if err := w.Flush(); err != nil {
-			logs.LogError("Error compressing with Snappy %v", err)
+			es.log().Error("Error compressing with Snappy", "error", err)
 			//return err  // TODO we should return error if we cannot compress
 		}
 		if err := w.Close(); err != nil {
-			logs.LogError("Error compressing with Snappy %v", err)
+			es.log().Error("Error compressing with Snappy", "error", err)
 			//return err  // TODO we should return error if we cannot compress
 		}
 		entry.sizeInBytes += len(tmp.Bytes())
@@ -202,12 +217,12 @@ func (es compressSnappy) UnCompress(source *bufio.Reader, dataSize, uncompressed
 	/// array of compress data
 
 	if err != nil {
-		logs.LogError("SNAPPY Error during reading buffer %s", err)
+		es.log().Error("SNAPPY Error during reading buffer", "error", err)
 	}
 
 	reader := snappy.NewReader(bytes.NewBuffer(zipperBuffer))
 	if err != nil {
-		logs.LogError("Error creating SNAPPY NewReader  %s", err)
+		es.log().Error("Error creating SNAPPY NewReader", "error", err)
 	}
 	defer reader.Reset(nil)
 	/// headers ---> payload --> headers --> payload (compressed)
@@ -215,7 +230,7 @@ func (es compressSnappy) UnCompress(source *bufio.Reader, dataSize, uncompressed
 	// Read in data.
 	uncompressedReader, err := ioutil.ReadAll(reader)
 	if err != nil {
-		logs.LogError("Error during reading buffer %s", err)
+		es.log().Error("Error during reading buffer", "error", err)
 	}
 	if uint32(len(uncompressedReader)) != uncompressedDataSize {
 		panic("uncompressedDataSize != count")
@@ -228,6 +243,7 @@ func (es compressSnappy) UnCompress(source *bufio.Reader, dataSize, uncompressed
 }
 
 type compressLZ4 struct {
+	compressLogger
 }
 
 func (es compressLZ4) Compress(subEntries *subEntries) {
@@ -237,20 +253,20 @@ func (es compressLZ4) Compress(subEntries *subEntries) {
 		for _, msg := range entry.messages {
 			size := len(msg.messageBytes)
 			if _, err := w.Write(bytesFromInt(uint32(size))); err != nil {
-				logs.LogError("Error compressing with LZ4 %v", err)
+				es.log().Error("Error compressing with LZ4", "error", err)
 				//return err  // TODO we should return error if we cannot compress
 			}
 			if _, err := w.Write(msg.messageBytes); err != nil {
-				logs.LogError("Error compressing with LZ4 %v", err)
+				es.log().Error("Error compressing with LZ4", "error", err)
 				//return err  // TODO we should return error if we cannot compress
 			}
 		}
 		if err := w.Flush(); err != nil {
-			logs.LogError("Error compressing with LZ4 %v", err)
+			es.log().Error("Error compressing with LZ4", "error", err)
 			//return err  // TODO we should return error if we cannot compress
 		}
 		if err := w.Close(); err != nil {
-			logs.LogError("Error compressing with LZ4 %v", err)
+			es.log().Error("Error compressing with LZ4", "error", err)
 			//return err  // TODO we should return error if we cannot compress
 		}
 		entry.sizeInBytes += len(tmp.Bytes())
@@ -267,7 +283,7 @@ func (es compressLZ4) UnCompress(source *bufio.Reader, dataSize, uncompressedDat
 	/// array of compress data
 
 	if err != nil {
-		logs.LogError("LZ4 Error during reading buffer %s", err)
+		es.log().Error("LZ4 Error during reading buffer", "error", err)
 	}
 
 	reader := lz4.NewReader(bytes.NewBuffer(zipperBuffer))
@@ -277,7 +293,7 @@ func (es compressLZ4) UnCompress(source *bufio.Reader, dataSize, uncompressedDat
 	// Read in data.
 	uncompressedReader, err := ioutil.ReadAll(reader)
 	if err != nil {
-		logs.LogError("Error during reading buffer %s", err)
+		es.log().Error("Error during reading buffer", "error", err)
 	}
 	if uint32(len(uncompressedReader)) != uncompressedDataSize {
 		panic("uncompressedDataSize != count")
@@ -290,6 +306,7 @@ func (es compressLZ4) UnCompress(source *bufio.Reader, dataSize, uncompressedDat
 }
 
 type compressZSTD struct {
+	compressLogger
 }
 
 func (es compressZSTD) Compress(subEntries *subEntries) {
@@ -297,27 +314,27 @@ func (es compressZSTD) Compress(subEntries *subEntries) {
 		var tmp bytes.Buffer
 		w, err := zstd.NewWriter(&tmp)
 		if err != nil {
-			logs.LogError("Error creating ZSTD compression algorithm writer %v", err)
+			es.log().Error("Error creating ZSTD compression algorithm writer", "error", err)
 			//return err  // TODO we should return error if we cannot compress
 		}
 
 		for _, msg := range entry.messages {
 			size := len(msg.messageBytes)
 			if _, err := w.Write(bytesFromInt(uint32(size))); err != nil {
-				logs.LogError("Error compressing with ZSTD %v", err)
+				es.log().Error("Error compressing with ZSTD", "error", err)
 				//return err  // TODO we should return error if we cannot compress
 			}
 			if _, err := w.Write(msg.messageBytes); err != nil {
-				logs.LogError("Error compressing with ZSTD %v", err)
+				es.log().Error("Error compressing with ZSTD", "error", err)
 				//return err  // TODO we should return error if we cannot compress
 			}
 		}
 		if err := w.Flush(); err != nil {
-			logs.LogError("Error compressing with ZSTD %v", err)
+			es.log().Error("Error compressing with ZSTD", "error", err)
 			//return err  // TODO we should return error if we cannot compress
 		}
 		if err := w.Close(); err != nil {
-			logs.LogError("Error compressing with ZSTD %v", err)
+			es.log().Error("Error compressing with ZSTD", "error", err)
 			//return err  // TODO we should return error if we cannot compress
 		}
 		entry.sizeInBytes += len(tmp.Bytes())
@@ -334,12 +351,12 @@ func (es compressZSTD) UnCompress(source *bufio.Reader, dataSize, uncompressedDa
 	/// array of compress data
 
 	if err != nil {
-		logs.LogError("ZSTD Error during reading buffer %s", err)
+		es.log().Error("ZSTD Error during reading buffer", "error", err)
 	}
 
 	reader, err := zstd.NewReader(bytes.NewBuffer(zipperBuffer))
 	if err != nil {
-		logs.LogError("Error creating ZSTD NewReader  %s", err)
+		es.log().Error("Error creating ZSTD NewReader", "error", err)
 	}
 	defer reader.Reset(nil)
 	/// headers ---> payload --> headers --> payload (compressed)
@@ -347,7 +364,7 @@ func (es compressZSTD) UnCompress(source *bufio.Reader, dataSize, uncompressedDa
 	// Read in data.
 	uncompressedReader, err := ioutil.ReadAll(reader)
 	if err != nil {
-		logs.LogError("Error during reading buffer %s", err)
+		es.log().Error("Error during reading buffer", "error", err)
 	}
 	if uint32(len(uncompressedReader)) != uncompressedDataSize {
 		panic("uncompressedDataSize != count")
@@ -0,0 +1,190 @@
+package stream
+
+import (
+	"time"
+)
+
+// BackPressurePolicy is what Send and BatchSend do when the producer is full: the queue of
+// QueueSize messages is full or there are MaxUnConfirmed messages waiting for the confirmation
+type BackPressurePolicy string
+
+const (
+	// BackPressureBlock blocks until there is room, it is the default
+	BackPressureBlock BackPressurePolicy = "block"
+	// BackPressureBlockTimeout blocks up to BackPressureTimeout, then returns ProducerQueueFull
+	BackPressureBlockTimeout BackPressurePolicy = "block-timeout"
+	// BackPressureFailFast returns ProducerQueueFull without blocking
+	BackPressureFailFast BackPressurePolicy = "fail-fast"
+	// BackPressureDropOldest drops the oldest queued message to make room, its confirmation has
+	// the MessageDropped error (see ConfirmationStatus.IsDropped).
+	// The messages already sent to the broker can't be dropped, in this case it returns ProducerQueueFull
+	BackPressureDropOldest BackPressurePolicy = "drop-oldest"
+)
+
+func (p BackPressurePolicy) isValid() bool {
+	switch p {
+	case "", BackPressureBlock, BackPressureBlockTimeout, BackPressureFailFast, BackPressureDropOldest:
+		return true
+	}
+	return false
+}
+
+// waitForRoom applies the policy to the MaxUnConfirmed limit before sending n messages.
+// A batch bigger than the limit is accepted when there are no unconfirmed messages
+func (producer *Producer) waitForRoom(n int) error {
+	return producer.waitForRoomWith(producer.options.BackPressure, n)
+}
+
+func (producer *Producer) waitForRoomWith(policy BackPressurePolicy, n int) error {
+	maxUnConfirmed := producer.options.MaxUnConfirmed
+	if maxUnConfirmed <= 0 {
+		return nil
+	}
+	hasRoom := func() bool {
+		unConfirmed := producer.lenUnConfirmed()
+		return unConfirmed == 0 || unConfirmed+n <= maxUnConfirmed
+	}
+	if hasRoom() {
+		return nil
+	}
+
+	switch policy {
+	case BackPressureFailFast:
+		return ProducerQueueFull
+	case BackPressureDropOldest:
+		for !hasRoom() {
+			if !producer.dropOldest() {
+				return ProducerQueueFull
+			}
+		}
+		return nil
+	}
+
+	var timeout <-chan time.Time
+	if policy == BackPressureBlockTimeout {
+		timer := time.NewTimer(producer.options.BackPressureTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		// the channel is taken before the check, so a confirmation in between is not missed
+		roomChanged := producer.roomChanged()
+		if hasRoom() {
+			return nil
+		}
+		select {
+		case <-roomChanged:
+		case <-producer.done:
+			// the send returns the error
+			return nil
+		case <-timeout:
+			return ProducerQueueFull
+		}
+	}
+}
+
+// roomChanged returns a channel closed when an unconfirmed message is removed
+func (producer *Producer) roomChanged() <-chan struct{} {
+	producer.mutex.Lock()
+	defer producer.mutex.Unlock()
+	if producer.roomSignal == nil {
+		producer.roomSignal = make(chan struct{})
+	}
+	return producer.roomSignal
+}
+
+// signalRoomLocked wakes up the sends waiting for MaxUnConfirmed, producer.mutex must be held
+func (producer *Producer) signalRoomLocked() {
+	if producer.roomSignal != nil {
+		close(producer.roomSignal)
+		producer.roomSignal = nil
+	}
+}
+
+// enqueue applies the policy to the queue of QueueSize messages
+func (producer *Producer) enqueue(msg messageSequence) error {
+	return producer.enqueueWith(producer.options.BackPressure, msg)
+}
+
+func (producer *Producer) enqueueWith(policy BackPressurePolicy, msg messageSequence) error {
+	select {
+	case producer.messageSequenceCh <- msg:
+		return nil
+	default:
+	}
+
+	switch policy {
+	case BackPressureFailFast:
+		return ProducerQueueFull
+	case BackPressureBlockTimeout:
+		timer := time.NewTimer(producer.options.BackPressureTimeout)
+		defer timer.Stop()
+		select {
+		case producer.messageSequenceCh <- msg:
+			return nil
+		case <-timer.C:
+			return ProducerQueueFull
+		}
+	case BackPressureDropOldest:
+		for {
+			select {
+			case producer.messageSequenceCh <- msg:
+				return nil
+			default:
+				// when nothing is dropped the publish task emptied the queue in the meantime
+				producer.dropOldest()
+			}
+		}
+	}
+	producer.messageSequenceCh <- msg
+	return nil
+}
+
+// dropOldest removes the oldest message not sent yet and sends its confirmation with MessageDropped.
+// The messages waiting for BatchPublishingDelay are older than the queue.
+// It returns false when there are no messages to drop
+func (producer *Producer) dropOldest() bool {
+	var msg messageSequence
+	producer.mutexPending.Lock()
+	if len(producer.pendingMessages.messages) > 0 {
+		msg = producer.pendingMessages.messages[0]
+		producer.pendingMessages.messages = producer.pendingMessages.messages[1:]
+		producer.pendingMessages.size -= msg.unCompressedSize
+		producer.mutexPending.Unlock()
+	} else {
+		producer.mutexPending.Unlock()
+		select {
+		case queued, running := <-producer.messageSequenceCh:
+			if !running {
+				return false
+			}
+			msg = queued
+		default:
+			return false
+		}
+	}
+
+	// the outbox and the confirmation channel can block, they are not used under the lock
+	producer.mutex.Lock()
+	dropped := producer.unConfirmedMessages[msg.publishingId]
+	if dropped == nil {
+		producer.mutex.Unlock()
+		return true
+	}
+	delete(producer.unConfirmedMessages, msg.publishingId)
+	producer.signalRoomLocked()
+	dropped.confirmed = false
+	dropped.err = MessageDropped
+	dropped.errorCode = droppedError
+	publishConfirm := producer.publishConfirm
+	producer.mutex.Unlock()
+
+	producer.outboxRemove([]int64{msg.publishingId})
+	dropped.resolveFuture()
+	if publishConfirm != nil {
+		publishConfirm <- []*ConfirmationStatus{dropped}
+	}
+	producer.options.client.logger.Debug("Message dropped by the back-pressure policy",
+		"producer_id", producer.id, "publishing_id", msg.publishingId)
+	return true
+}
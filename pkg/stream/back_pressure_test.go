@@ -0,0 +1,133 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
+)
+
+var _ = Describe("Back-pressure policy", func() {
+	var (
+		env        *Environment
+		streamName string
+	)
+
+	BeforeEach(func() {
+		var err error
+		env, err = NewEnvironment(nil)
+		Expect(err).NotTo(HaveOccurred())
+		streamName = uuid.New().String()
+		Expect(env.DeclareStream(streamName, nil)).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(env.DeleteStream(streamName)).NotTo(HaveOccurred())
+		Expect(env.Close()).NotTo(HaveOccurred())
+	})
+
+	// the messages wait BatchPublishingDelay, so the first 5 are not confirmed before the next Send
+	newFullProducer := func(policy BackPressurePolicy, timeout time.Duration) *Producer {
+		producer, err := env.NewProducer(streamName, NewProducerOptions().
+			SetBatchPublishingDelay(500).
+			SetMaxUnConfirmed(5).
+			SetBackPressure(policy, timeout))
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 5; i++ {
+			Expect(producer.Send(amqp.NewMessage([]byte("hello")))).NotTo(HaveOccurred())
+		}
+		return producer
+	}
+
+	It("Validate the options", func() {
+		_, err := env.NewProducer(streamName, NewProducerOptions().SetBackPressure("drop-newest", 0))
+		Expect(err).To(HaveOccurred())
+		_, err = env.NewProducer(streamName, NewProducerOptions().SetBackPressure(BackPressureBlockTimeout, 0))
+		Expect(err).To(HaveOccurred())
+		_, err = env.NewProducer(streamName, NewProducerOptions().SetMaxUnConfirmed(-1))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Fail fast", func() {
+		producer := newFullProducer(BackPressureFailFast, 0)
+		Expect(producer.Send(amqp.NewMessage([]byte("full")))).To(Equal(ProducerQueueFull))
+		Expect(producer.BatchSend(CreateArrayMessagesForTesting(2))).To(Equal(ProducerQueueFull))
+		Eventually(producer.lenUnConfirmed, time.Second*5).Should(Equal(0))
+		Expect(producer.Send(amqp.NewMessage([]byte("room")))).NotTo(HaveOccurred())
+		Expect(producer.Close()).NotTo(HaveOccurred())
+	})
+
+	It("Block and block with timeout", func() {
+		producer := newFullProducer(BackPressureBlockTimeout, 50*time.Millisecond)
+		start := time.Now()
+		Expect(producer.Send(amqp.NewMessage([]byte("full")))).To(Equal(ProducerQueueFull))
+		Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+
+		producer = newFullProducer(BackPressureBlock, 0)
+		Expect(producer.SendAndWait(context.Background(), amqp.NewMessage([]byte("waits")))).NotTo(HaveOccurred())
+		Expect(producer.Close()).NotTo(HaveOccurred())
+	})
+
+	It("Drop oldest", func() {
+		producer := newFullProducer(BackPressureDropOldest, 0)
+		var dropped int64
+		go func(ch ChannelPublishConfirm) {
+			defer GinkgoRecover()
+			for statuses := range ch {
+				for _, s := range statuses {
+					if s.IsDropped() {
+						Expect(s.GetError()).To(Equal(MessageDropped))
+						atomic.StoreInt64(&dropped, s.GetPublishingId())
+					}
+				}
+			}
+		}(producer.NotifyPublishConfirmation())
+
+		status, err := producer.SendAsync(amqp.NewMessage([]byte("newest"))).Wait()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.GetPublishingId()).To(Equal(int64(6)))
+		// the oldest of the 5 messages waiting for BatchPublishingDelay
+		Eventually(func() int64 { return atomic.LoadInt64(&dropped) }, time.Second*5).Should(Equal(int64(1)))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+	})
+})
+
+var _ = Describe("Back-pressure wait", func() {
+	newWaitingProducer := func() *Producer {
+		options := NewProducerOptions().SetMaxUnConfirmed(1)
+		options.client = newClient("back-pressure-test", nil, nil, nil, time.Second)
+		producer := &Producer{mutex: &sync.Mutex{}, mutexPending: &sync.Mutex{}, done: make(chan struct{}),
+			unConfirmedMessages: map[int64]*ConfirmationStatus{}, status: open, options: options}
+		producer.addUnConfirmed(1, amqp.NewMessage([]byte("unconfirmed")), 0, nil)
+		return producer
+	}
+	wait := func(producer *Producer) <-chan error {
+		result := make(chan error, 1)
+		go func() {
+			result <- producer.waitForRoomWith(BackPressureBlock, 1)
+		}()
+		return result
+	}
+
+	It("wakes up on the confirmation", func() {
+		producer := newWaitingProducer()
+		result := wait(producer)
+		Consistently(result, 50*time.Millisecond).ShouldNot(Receive())
+		producer.removeUnConfirmed(1)
+		Eventually(result, time.Second).Should(Receive(BeNil()))
+	})
+
+	It("wakes up when the producer is closed", func() {
+		producer := newWaitingProducer()
+		result := wait(producer)
+		Consistently(result, 50*time.Millisecond).ShouldNot(Receive())
+		producer.setStatus(closed)
+		Eventually(result, time.Second).Should(Receive(BeNil()))
+	})
+})
@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy defines the delay between the reconnection attempts.
+// NextDelay is called before each attempt, starting from 1, and returns the delay
+// to wait before the attempt. It returns false when there are no more attempts,
+// in this case the caller returns an ErrRetriesExhausted error.
+// It is used by the Environment to connect the locators and by the ha
+// ReliableProducer and ReliableConsumer to reconnect.
+type BackoffPolicy interface {
+	NextDelay(attempt int) (time.Duration, bool)
+}
+
+func defaultBackoffPolicy() BackoffPolicy {
+	return NewExponentialBackoff(defaultBackoffInitialDelay, defaultBackoffMaxDelay)
+}
+
+// NewExponentialBackoff doubles the delay for each attempt, starting from initial
+// and up to max. A random jitter between delay/2 and delay avoids that many clients
+// reconnect at the same time. It never stops retrying, see NewMaxAttemptsBackoff.
+func NewExponentialBackoff(initial time.Duration, max time.Duration) BackoffPolicy {
+	return &exponentialBackoff{
+		initial: initial,
+		max:     max,
+	}
+}
+
+type exponentialBackoff struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+func (e *exponentialBackoff) NextDelay(attempt int) (time.Duration, bool) {
+	delay := e.max
+	if attempt < 1 {
+		attempt = 1
+	}
+	// the shift overflows with too many attempts
+	if attempt < 32 {
+		if d := e.initial << (attempt - 1); d > 0 && d < e.max {
+			delay = d
+		}
+	}
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay, true
+	}
+	return time.Duration(half + rand.Int63n(half+1)), true
+}
+
+// NewConstantBackoff waits the same delay for each attempt. It never stops retrying.
+func NewConstantBackoff(delay time.Duration) BackoffPolicy {
+	return &constantBackoff{delay: delay}
+}
+
+type constantBackoff struct {
+	delay time.Duration
+}
+
+func (c *constantBackoff) NextDelay(_ int) (time.Duration, bool) {
+	return c.delay, true
+}
+
+// NewMaxAttemptsBackoff limits the attempts of the policy to maxAttempts
+func NewMaxAttemptsBackoff(policy BackoffPolicy, maxAttempts int) BackoffPolicy {
+	return &maxAttemptsBackoff{
+		policy:      policy,
+		maxAttempts: maxAttempts,
+	}
+}
+
+type maxAttemptsBackoff struct {
+	policy      BackoffPolicy
+	maxAttempts int
+}
+
+func (m *maxAttemptsBackoff) NextDelay(attempt int) (time.Duration, bool) {
+	if attempt > m.maxAttempts {
+		return 0, false
+	}
+	return m.policy.NextDelay(attempt)
+}
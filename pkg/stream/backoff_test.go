@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backoff policies", func() {
+
+	It("Exponential backoff with jitter", func() {
+		policy := NewExponentialBackoff(100*time.Millisecond, time.Second)
+		for attempt := 1; attempt <= 100; attempt++ {
+			expected := 100 * time.Millisecond << (attempt - 1)
+			if attempt > 4 {
+				expected = time.Second
+			}
+			delay, retry := policy.NextDelay(attempt)
+			Expect(retry).To(BeTrue())
+			Expect(delay).To(BeNumerically(">=", expected/2))
+			Expect(delay).To(BeNumerically("<=", expected))
+		}
+	})
+
+	It("Constant backoff", func() {
+		policy := NewConstantBackoff(200 * time.Millisecond)
+		for attempt := 1; attempt <= 10; attempt++ {
+			delay, retry := policy.NextDelay(attempt)
+			Expect(retry).To(BeTrue())
+			Expect(delay).To(Equal(200 * time.Millisecond))
+		}
+	})
+
+	It("Max attempts backoff", func() {
+		policy := NewMaxAttemptsBackoff(NewConstantBackoff(time.Millisecond), 3)
+		for attempt := 1; attempt <= 3; attempt++ {
+			_, retry := policy.NextDelay(attempt)
+			Expect(retry).To(BeTrue())
+		}
+		_, retry := policy.NextDelay(4)
+		Expect(retry).To(BeFalse())
+	})
+
+	It("Environment retries exhausted", func() {
+		env, err := NewEnvironment(NewEnvironmentOptions().
+			SetBackoffPolicy(NewMaxAttemptsBackoff(NewConstantBackoff(10*time.Millisecond), 2)))
+		Expect(err).NotTo(HaveOccurred())
+		env.options.ConnectionParameters[0].Port = "5999"
+		env.options.ConnectionParameters[0].Uri = ""
+		_, err = env.StreamExists("retries-exhausted")
+		Expect(err).To(MatchError(ErrRetriesExhausted))
+		Expect(env.Close()).NotTo(HaveOccurred())
+	})
+
+	It("Locator dials once for each attempt", func() {
+		var dialed int32
+		options := NewEnvironmentOptions().
+			SetBackoffPolicy(NewMaxAttemptsBackoff(NewConstantBackoff(time.Millisecond), 3)).
+			SetDialer(func(_ context.Context, _ string, _ string) (net.Conn, error) {
+				atomic.AddInt32(&dialed, 1)
+				return nil, errors.New("no route")
+			})
+		options.ConnectionParameters = []*Broker{newBrokerDefault()}
+		env := &Environment{
+			options: options,
+			clientWiring: clientWiring{
+				logger:  options.Logger,
+				metrics: options.MetricsCollector,
+				secret:  newSecretHolder(),
+			},
+		}
+		_, release, err := env.locatorClient(context.Background())
+		release()
+		Expect(err).To(MatchError(ErrRetriesExhausted))
+		Expect(atomic.LoadInt32(&dialed)).To(Equal(int32(3)))
+	})
+})
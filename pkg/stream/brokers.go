@@ -1,8 +1,10 @@
 package stream
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,6 +16,10 @@ type AddressResolver struct {
 	Port int
 }
 
+// Dialer opens the connection to the broker, addr is host:port.
+// It can be used to connect through a proxy, bind a source address or inject a net.Conn in the tests
+type Dialer func(ctx context.Context, network string, addr string) (net.Conn, error)
+
 type TCPParameters struct {
 	tlsConfig             *tls.Config
 	RequestedHeartbeat    time.Duration
@@ -21,6 +27,9 @@ type TCPParameters struct {
 	WriteBuffer           int
 	ReadBuffer            int
 	NoDelay               bool
+	// Dialer replaces the default net.Dialer. The buffers and NoDelay are applied only when
+	// the connection is a *net.TCPConn, TLS is applied on top of the connection
+	Dialer Dialer
 }
 
 type Broker struct {
@@ -3,6 +3,7 @@ package stream
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -19,12 +20,15 @@ import (
 
 // SaslConfiguration see
 //
-//	SaslConfigurationPlain    = "PLAIN"
-//	SaslConfigurationExternal = "EXTERNAL"
+//	SaslConfigurationPlain       = "PLAIN"
+//	SaslConfigurationExternal    = "EXTERNAL"
+//	SaslConfigurationScramSha256 = "SCRAM-SHA-256"
 //
+// SaslMechanism, when set, replaces the Mechanism name with a custom implementation
 
 type SaslConfiguration struct {
-	Mechanism string
+	Mechanism     string
+	SaslMechanism SaslMechanism
 }
 
 func newSaslConfigurationDefault() *SaslConfiguration {
@@ -69,6 +73,17 @@ type Client struct {
 	socketCallTimeout time.Duration
 	availableFeatures *availableFeatures
 	serverProperties  map[string]string
+	connectionEvents  *connectionEventsNotifier
+	logger            logs.Logger
+	metrics           MetricsCollector
+	// metadataCache is set only on the locator clients of an environment
+	metadataCache *metadataCache
+	// onClose receives the streams of the producers and consumers when the connection is closed
+	onClose func(streams []string)
+	// rateLimiter is the limiter shared by the producers of an environment
+	rateLimiter *RateLimiter
+	// secret, when set, replaces the password of the broker, see UpdateSecret
+	secret *secretHolder
 }
 
 func newClient(connectionName string, broker *Broker,
@@ -103,11 +118,26 @@ func newClient(connectionName string, broker *Broker,
 		},
 		socketCallTimeout: rpcTimeOut,
 		availableFeatures: newAvailableFeatures(),
+		logger:            logs.NewStandardLogger(),
+		metrics:           NoOpMetricsCollector{},
+		secret:            newSecretHolder(),
 	}
 	c.setConnectionName(connectionName)
 	return c
 }
 
+// wiring returns the shared state of the environment set on the client, see clientWiring
+func (c *Client) wiring() clientWiring {
+	return clientWiring{
+		connectionEvents: c.connectionEvents,
+		logger:           c.logger,
+		metrics:          c.metrics,
+		metadataCache:    c.metadataCache,
+		rateLimiter:      c.rateLimiter,
+		secret:           c.secret,
+	}
+}
+
 func (c *Client) getSocket() *socket {
 	//c.mutex.Lock()
 	//defer c.mutex.Unlock()
@@ -140,6 +170,13 @@ func (c *Client) setLastHeartBeat(value time.Time) {
 }
 
 func (c *Client) connect() error {
+	return c.connectCtx(context.Background())
+}
+
+// connectCtx opens the connection if it is not already open.
+// The context is used for the dial, the TLS handshake and
+// all the calls needed to open the connection: peer properties, authentication, tune and open.
+func (c *Client) connectCtx(ctx context.Context) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	if !c.socket.isOpen() {
@@ -152,28 +189,34 @@ func (c *Client) connect() error {
 		c.tuneState.requestedHeartbeat = int(c.tcpParameters.RequestedHeartbeat.Seconds())
 
 		servAddr := net.JoinHostPort(host, port)
-		tcpAddr, errorResolve := net.ResolveTCPAddr("tcp", servAddr)
-		if errorResolve != nil {
-			logs.LogDebug("Resolve error %s", errorResolve)
-			return errorResolve
+		dial := c.tcpParameters.Dialer
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
 		}
-		connection, errorConnection := net.DialTCP("tcp", nil, tcpAddr)
+		connection, errorConnection := dial(ctx, "tcp", servAddr)
 		if errorConnection != nil {
-			logs.LogDebug("%s", errorConnection)
+			c.logger.Debug("Can't connect", "broker", servAddr, "error", errorConnection)
+			c.notifyConnectionEvent(ConnectionEventFailed, "dial", errorConnection)
 			return errorConnection
 		}
 
-		if err = connection.SetWriteBuffer(c.tcpParameters.WriteBuffer); err != nil {
-			logs.LogError("Failed to SetWriteBuffer to %d due to %v", c.tcpParameters.WriteBuffer, err)
-			return err
-		}
-		if err = connection.SetReadBuffer(c.tcpParameters.ReadBuffer); err != nil {
-			logs.LogError("Failed to SetReadBuffer to %d due to %v", c.tcpParameters.ReadBuffer, err)
-			return err
-		}
-		if err = connection.SetNoDelay(c.tcpParameters.NoDelay); err != nil {
-			logs.LogError("Failed to SetNoDelay to %b due to %v", c.tcpParameters.NoDelay, err)
-			return err
+		// a custom dialer can return a connection that is not TCP, ex: a unix socket or a test pipe
+		if tcpConnection, ok := connection.(*net.TCPConn); ok {
+			if err = tcpConnection.SetWriteBuffer(c.tcpParameters.WriteBuffer); err != nil {
+				c.logger.Error("Failed to SetWriteBuffer", "broker", servAddr, "write_buffer", c.tcpParameters.WriteBuffer, "error", err)
+				_ = connection.Close()
+				return err
+			}
+			if err = tcpConnection.SetReadBuffer(c.tcpParameters.ReadBuffer); err != nil {
+				c.logger.Error("Failed to SetReadBuffer", "broker", servAddr, "read_buffer", c.tcpParameters.ReadBuffer, "error", err)
+				_ = connection.Close()
+				return err
+			}
+			if err = tcpConnection.SetNoDelay(c.tcpParameters.NoDelay); err != nil {
+				c.logger.Error("Failed to SetNoDelay", "broker", servAddr, "no_delay", c.tcpParameters.NoDelay, "error", err)
+				_ = connection.Close()
+				return err
+			}
 		}
 
 		if c.broker.isTLS() {
@@ -181,58 +224,81 @@ func (c *Client) connect() error {
 			if c.tcpParameters.tlsConfig != nil {
 				conf = c.tcpParameters.tlsConfig
 			}
-			c.setSocketConnection(tls.Client(connection, conf))
+			tlsConnection := tls.Client(connection, conf)
+			if err = tlsConnection.HandshakeContext(ctx); err != nil {
+				c.logger.Debug("TLS handshake error", "broker", servAddr, "error", err)
+				_ = connection.Close()
+				c.notifyConnectionEvent(ConnectionEventFailed, "TLS handshake", err)
+				return err
+			}
+			c.setSocketConnection(tlsConnection)
 		} else {
 			c.setSocketConnection(connection)
 		}
 
 		c.socket.setOpen()
+		c.notifyConnectionEvent(ConnectionEventConnected, "", nil)
 
 		go c.handleResponse()
-		serverProperties, err2 := c.peerProperties()
-		c.serverProperties = serverProperties
-		if err2 != nil {
-			logs.LogError("Can't set the peer-properties. Check if the stream server is running/reachable")
-			return err2
+		if err = c.handshake(ctx, u, servAddr); err != nil {
+			// the socket is open but the connection is not usable: close it.
+			// The close is final (socket and heartbeat destructors), the callers
+			// discard this Client and connect a new one
+			_ = c.closeWithReason("connection setup failed")
+			return err
 		}
+	}
+	return nil
+}
 
-		pwd, _ := u.User.Password()
-		err2 = c.authenticate(u.User.Username(), pwd)
-		if err2 != nil {
-			logs.LogDebug("User:%s, %s", u.User.Username(), err2)
-			return err2
-		}
-		vhost := "/"
-		if len(u.Path) > 1 {
-			vhost, _ = url.QueryUnescape(u.Path[1:])
-		}
-		err2 = c.open(vhost)
-		if err2 != nil {
-			logs.LogDebug("%s", err2)
-			return err2
-		}
+// handshake runs the calls needed to open the connection once the socket is connected:
+// peer properties, authentication, tune, open and the command versions exchange
+func (c *Client) handshake(ctx context.Context, u *url.URL, servAddr string) error {
+	serverProperties, err := c.peerProperties(ctx)
+	c.serverProperties = serverProperties
+	if err != nil {
+		c.logger.Error("Can't set the peer-properties. Check if the stream server is running/reachable", "broker", servAddr, "error", err)
+		return err
+	}
 
-		err = c.availableFeatures.SetVersion(serverProperties["version"])
-		if err != nil {
-			logs.LogWarn("Error checking server version: %s", err)
-		}
+	pwd, _ := u.User.Password()
+	if secret, ok := c.secret.get(); ok {
+		pwd = secret
+	}
+	err = c.authenticate(ctx, u.User.Username(), pwd)
+	if err != nil {
+		c.logger.Debug("Authentication failed", "broker", servAddr, "user", u.User.Username(), "error", err)
+		c.notifyConnectionEvent(ConnectionEventAuthenticationFailed, c.saslConfiguration.mechanismName(), err)
+		return err
+	}
+	c.notifyConnectionEvent(ConnectionEventAuthenticated, c.saslConfiguration.mechanismName(), nil)
+	vhost := "/"
+	if len(u.Path) > 1 {
+		vhost, _ = url.QueryUnescape(u.Path[1:])
+	}
+	err = c.open(ctx, vhost)
+	if err != nil {
+		c.logger.Debug("Can't open the connection", "broker", servAddr, "vhost", vhost, "error", err)
+		return err
+	}
 
-		if serverProperties["version"] == "" || !c.availableFeatures.Is311OrMore() {
-			logs.LogDebug(
-				"Server version is less than 3.11.0, skipping command version exchange")
-		} else {
-			err := c.exchangeVersion(c.serverProperties["version"])
-			if err != nil {
-				return err
-			}
-			logs.LogDebug("available features: %s", c.availableFeatures)
-		}
+	if errVersion := c.availableFeatures.SetVersion(serverProperties["version"]); errVersion != nil {
+		c.logger.Warn("Error checking server version", "broker", servAddr, "error", errVersion)
+	}
 
-		c.heartBeat()
-		logs.LogDebug("User %s, connected to: %s, vhost:%s", u.User.Username(),
-			net.JoinHostPort(host, port),
-			vhost)
+	if serverProperties["version"] == "" || !c.availableFeatures.Is311OrMore() {
+		c.logger.Debug("Server version is less than 3.11.0, skipping command version exchange", "broker", servAddr)
+	} else {
+		err = c.exchangeVersion(ctx, c.serverProperties["version"])
+		if err != nil {
+			return err
+		}
+		c.logger.Debug("available features", "broker", servAddr, "features", c.availableFeatures)
 	}
+
+	c.heartBeat()
+	c.logger.Debug("Connected", "user", u.User.Username(), "broker", servAddr, "vhost", vhost,
+		"connection_name", c.getConnectionName())
 	return nil
 }
 
@@ -240,7 +306,11 @@ func (c *Client) setConnectionName(connectionName string) {
 	c.clientProperties.items["connection_name"] = connectionName
 }
 
-func (c *Client) peerProperties() (map[string]string, error) {
+func (c *Client) getConnectionName() string {
+	return c.clientProperties.items["connection_name"]
+}
+
+func (c *Client) peerProperties(ctx context.Context) (map[string]string, error) {
 	clientPropertiesSize := 4 // size of the map, always there
 
 	c.clientProperties.items["product"] = "RabbitMQ Stream"
@@ -265,8 +335,9 @@ func (c *Client) peerProperties() (map[string]string, error) {
 		writeString(b, element)
 	}
 
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return nil, err.Err
 	}
 
@@ -275,16 +346,20 @@ func (c *Client) peerProperties() (map[string]string, error) {
 	return serverProperties.(map[string]string), nil
 }
 
-func (c *Client) authenticate(user string, password string) error {
+func (c *Client) authenticate(ctx context.Context, user string, password string) error {
+	mechanism, err := c.saslConfiguration.saslMechanism()
+	if err != nil {
+		return err
+	}
 
-	saslMechanisms, err := c.getSaslMechanisms()
+	saslMechanisms, err := c.getSaslMechanisms(ctx)
 	if err != nil {
 		return err
 	}
 	saslMechanism := ""
 	for i := 0; i < len(saslMechanisms); i++ {
-		if saslMechanisms[i] == c.saslConfiguration.Mechanism {
-			saslMechanism = c.saslConfiguration.Mechanism
+		if saslMechanisms[i] == mechanism.Name() {
+			saslMechanism = mechanism.Name()
 			break
 		}
 	}
@@ -292,12 +367,38 @@ func (c *Client) authenticate(user string, password string) error {
 		return fmt.Errorf("no matching mechanism found")
 	}
 
-	response := unicodeNull + user + unicodeNull + password
-	saslResponse := []byte(response)
-	return c.sendSaslAuthenticate(saslMechanism, saslResponse)
+	exchange, err := mechanism.Start(user, password)
+	if err != nil {
+		return err
+	}
+
+	// the tune frame is sent by the server as soon as the authentication succeeds
+	respTune := c.coordinator.NewResponseWitName("tune")
+	err = saslConversation(exchange, func(response []byte) ([]byte, error) {
+		return c.sendSaslAuthenticate(ctx, saslMechanism, response)
+	})
+	if err != nil {
+		_ = c.coordinator.RemoveResponseByName("tune")
+		return err
+	}
+
+	// double read for TUNE
+	var tuneData interface{}
+	select {
+	case tuneData = <-respTune.data:
+	case <-ctx.Done():
+		_ = c.coordinator.RemoveResponseByName("tune")
+		return ctx.Err()
+	}
+	errR := c.coordinator.RemoveResponseByName("tune")
+	if errR != nil {
+		return errR
+	}
+
+	return c.socket.writeAndFlush(tuneData.([]byte))
 }
 
-func (c *Client) getSaslMechanisms() ([]string, error) {
+func (c *Client) getSaslMechanisms(ctx context.Context) ([]string, error) {
 	length := 2 + 2 + 4
 	resp := c.coordinator.NewResponse(commandSaslHandshake)
 	correlationId := resp.correlationid
@@ -306,7 +407,13 @@ func (c *Client) getSaslMechanisms() ([]string, error) {
 		correlationId)
 
 	errWrite := c.socket.writeAndFlush(b.Bytes())
-	data := <-resp.data
+	var data interface{}
+	select {
+	case data = <-resp.data:
+	case <-ctx.Done():
+		_ = c.coordinator.RemoveResponseById(correlationId)
+		return nil, ctx.Err()
+	}
 	err := c.coordinator.RemoveResponseById(correlationId)
 	if err != nil {
 		return nil, err
@@ -318,10 +425,12 @@ func (c *Client) getSaslMechanisms() ([]string, error) {
 
 }
 
-func (c *Client) sendSaslAuthenticate(saslMechanism string, challengeResponse []byte) error {
+// sendSaslAuthenticate sends a SASL response. When the broker replies with a new challenge
+// it returns the challenge and errSaslChallenge, with a success it returns the optional
+// data sent by the broker (ex: the SCRAM server-final message)
+func (c *Client) sendSaslAuthenticate(ctx context.Context, saslMechanism string, challengeResponse []byte) ([]byte, error) {
 	length := 2 + 2 + 4 + 2 + len(saslMechanism) + 4 + len(challengeResponse)
 	resp := c.coordinator.NewResponse(commandSaslAuthenticate)
-	respTune := c.coordinator.NewResponseWitName("tune")
 	correlationId := resp.correlationid
 	var b = bytes.NewBuffer(make([]byte, 0, length+4))
 	writeProtocolHeader(b, length, commandSaslAuthenticate,
@@ -330,21 +439,24 @@ func (c *Client) sendSaslAuthenticate(saslMechanism string, challengeResponse []
 	writeString(b, saslMechanism)
 	writeInt(b, len(challengeResponse))
 	b.Write(challengeResponse)
-	err := c.handleWrite(b.Bytes(), resp)
-	if err.Err != nil {
-		return err.Err
-	}
-	// double read for TUNE
-	tuneData := <-respTune.data
-	errR := c.coordinator.RemoveResponseByName("tune")
-	if errR != nil {
-		return errR
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
+	var challenge []byte
+	switch err.Err {
+	case errSaslChallenge:
+		challenge = (<-resp.data).([]byte)
+	case nil:
+		// the data of the success is optional, it is pushed before the response code
+		select {
+		case data := <-resp.data:
+			challenge = data.([]byte)
+		default:
+		}
 	}
-
-	return c.socket.writeAndFlush(tuneData.([]byte))
+	_ = c.coordinator.RemoveResponseById(correlationId)
+	return challenge, err.Err
 }
 
-func (c *Client) exchangeVersion(serverVersion string) error {
+func (c *Client) exchangeVersion(ctx context.Context, serverVersion string) error {
 	_ = c.availableFeatures.SetVersion(serverVersion)
 
 	commands := c.availableFeatures.GetCommands()
@@ -366,8 +478,9 @@ func (c *Client) exchangeVersion(serverVersion string) error {
 		writeUShort(b, command.GetMaxVersion())
 	}
 
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return err.Err
 	}
 
@@ -377,7 +490,7 @@ func (c *Client) exchangeVersion(serverVersion string) error {
 	return nil
 }
 
-func (c *Client) open(virtualHost string) error {
+func (c *Client) open(ctx context.Context, virtualHost string) error {
 	length := 2 + 2 + 4 + 2 + len(virtualHost)
 	resp := c.coordinator.NewResponse(commandOpen, virtualHost)
 	correlationId := resp.correlationid
@@ -385,8 +498,9 @@ func (c *Client) open(virtualHost string) error {
 	writeProtocolHeader(b, length, commandOpen,
 		correlationId)
 	writeString(b, virtualHost)
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return err.Err
 	}
 
@@ -400,6 +514,10 @@ func (c *Client) open(virtualHost string) error {
 }
 
 func (c *Client) DeleteStream(streamName string) error {
+	return c.DeleteStreamCtx(context.Background(), streamName)
+}
+
+func (c *Client) DeleteStreamCtx(ctx context.Context, streamName string) error {
 	length := 2 + 2 + 4 + 2 + len(streamName)
 	resp := c.coordinator.NewResponse(commandDeleteStream, streamName)
 	correlationId := resp.correlationid
@@ -409,7 +527,7 @@ func (c *Client) DeleteStream(streamName string) error {
 
 	writeString(b, streamName)
 
-	return c.handleWrite(b.Bytes(), resp).Err
+	return c.handleWriteCtx(ctx, b.Bytes(), resp).Err
 }
 
 func (c *Client) heartBeat() {
@@ -422,10 +540,12 @@ func (c *Client) heartBeat() {
 			<-tickerHeatBeat.C
 			if time.Since(c.getLastHeartBeat()) > time.Duration(c.tuneState.requestedHeartbeat)*time.Second {
 				v := atomic.AddInt32(&heartBeatMissed, 1)
-				logs.LogWarn("Missing heart beat: %d", v)
+				c.logger.Warn("Missing heart beat", "connection_name", c.getConnectionName(), "missed", v)
+				c.notifyConnectionEvent(ConnectionEventHeartbeatMissed, fmt.Sprintf("missed heartbeats: %d", v), nil)
+				c.metrics.HeartbeatMissed(c.getConnectionName())
 				if v >= 2 {
-					logs.LogWarn("Too many heartbeat missing: %d", v)
-					c.Close()
+					c.logger.Warn("Too many heartbeat missing", "connection_name", c.getConnectionName(), "missed", v)
+					c.closeWithReason("too many heartbeats missed")
 				}
 			} else {
 				atomic.StoreInt32(&heartBeatMissed, 0)
@@ -445,7 +565,7 @@ func (c *Client) heartBeat() {
 				ticker.Stop()
 				return
 			case <-ticker.C:
-				logs.LogDebug("Sending heart beat: %s", time.Now())
+				c.logger.Debug("Sending heart beat", "connection_name", c.getConnectionName())
 				c.sendHeartbeat()
 			}
 		}
@@ -464,7 +584,7 @@ func (c *Client) closeHartBeat() {
 	c.destructor.Do(func() {
 		r, err := c.coordinator.GetResponseByName("heartbeat")
 		if err != nil {
-			logs.LogDebug("error removing heartbeat: %s", err)
+			c.logger.Debug("error removing heartbeat", "connection_name", c.getConnectionName(), "error", err)
 		} else {
 			r.code <- Code{id: closeChannel}
 		}
@@ -473,6 +593,22 @@ func (c *Client) closeHartBeat() {
 }
 
 func (c *Client) Close() error {
+	return c.closeWithReason(SocketClosed)
+}
+
+// closeWithReason closes the client, the reason is sent with the ConnectionClosed event
+func (c *Client) closeWithReason(reason string) error {
+	var streams []string
+	for _, producer := range c.coordinator.producersList() {
+		streams = append(streams, producer.GetStreamName())
+	}
+	for _, consumer := range c.coordinator.consumersList() {
+		streams = append(streams, consumer.GetStreamName())
+	}
+	if c.onClose != nil && len(streams) > 0 {
+		c.onClose(streams)
+	}
+
 	for _, p := range c.coordinator.Producers() {
 		err := c.coordinator.RemoveProducerById(p.(*Producer).id, Event{
 			Command:    CommandClose,
@@ -483,7 +619,7 @@ func (c *Client) Close() error {
 		})
 
 		if err != nil {
-			logs.LogWarn("error removing producer: %s", err)
+			c.logger.Warn("error removing producer", "producer_id", p.(*Producer).id, "stream", p.(*Producer).GetStreamName(), "error", err)
 		}
 	}
 
@@ -496,7 +632,7 @@ func (c *Client) Close() error {
 			Err:        nil,
 		})
 		if err != nil {
-			logs.LogWarn("error removing consumer: %s", err)
+			c.logger.Warn("error removing consumer", "subscription_id", cs.(*Consumer).ID, "stream", cs.(*Consumer).GetStreamName(), "error", err)
 		}
 	}
 
@@ -517,56 +653,46 @@ func (c *Client) Close() error {
 
 		errW := c.socket.writeAndFlush(b.Bytes())
 		if errW != nil {
-			logs.LogWarn("error during Send client close %s", errW)
+			c.logger.Warn("error during Send client close", "connection_name", c.getConnectionName(), "error", errW)
 		}
 		_ = c.coordinator.RemoveResponseById(res.correlationid)
+		c.notifyConnectionEvent(ConnectionEventClosed, reason, nil)
+	}
+	if err := c.getSocket().shutdown(nil); err != nil {
+		c.logger.Warn("error during close socket", "connection_name", c.getConnectionName(), "error", err)
 	}
-	c.getSocket().shutdown(nil)
 	return nil
 }
 
 func (c *Client) DeclarePublisher(streamName string, options *ProducerOptions) (*Producer, error) {
-	if options == nil {
-		options = NewProducerOptions()
-	}
-
-	if options.IsFilterEnabled() && !c.availableFeatures.BrokerFilterEnabled() {
-		return nil, FilterNotSupported
-	}
-
-	if options.isSubEntriesBatching() && options.IsFilterEnabled() {
-		return nil, fmt.Errorf("sub-entry batching can't be enabled with filter")
-	}
-	if options.QueueSize < minQueuePublisherSize || options.QueueSize > maxQueuePublisherSize {
-		return nil, fmt.Errorf("QueueSize values must be between %d and %d",
-			minQueuePublisherSize, maxQueuePublisherSize)
-	}
+	return c.DeclarePublisherCtx(context.Background(), streamName, options)
+}
 
-	if options.BatchSize < minBatchSize || options.BatchSize > maxBatchSize {
-		return nil, fmt.Errorf("BatchSize values must be between %d and %d",
-			minBatchSize, maxBatchSize)
+func (c *Client) DeclarePublisherCtx(ctx context.Context, streamName string, options *ProducerOptions) (*Producer, error) {
+	producer, err := c.declarePublisherCtx(ctx, streamName, options)
+	if err != nil {
+		return producer, err
 	}
-
-	if options.BatchPublishingDelay < minBatchPublishingDelay || options.BatchPublishingDelay > maxBatchPublishingDelay {
-		return nil, fmt.Errorf("BatchPublishingDelay values must be between %d and %d",
-			minBatchPublishingDelay, maxBatchPublishingDelay)
+	if err := producer.replayOutbox(); err != nil {
+		_ = producer.Close()
+		return nil, err
 	}
+	return producer, nil
+}
 
-	if options.SubEntrySize < minSubEntrySize || options.SubEntrySize > maxSubEntrySize {
-		return nil, fmt.Errorf("SubEntrySize values must be between %d and %d",
-			minSubEntrySize, maxSubEntrySize)
+// declarePublisherCtx declares the producer without replaying the outbox,
+// the environment replays it once the locks of the coordinator are released
+func (c *Client) declarePublisherCtx(ctx context.Context, streamName string, options *ProducerOptions) (*Producer, error) {
+	if options == nil {
+		options = NewProducerOptions()
 	}
 
-	if !options.isSubEntriesBatching() {
-		if options.Compression.enabled {
-			return nil, fmt.Errorf("sub-entry batching must be enabled to enable compression")
-		}
+	if options.IsFilterEnabled() && !c.availableFeatures.BrokerFilterEnabled() {
+		return nil, FilterNotSupported
 	}
 
-	if !options.isSubEntriesBatching() {
-		if options.Compression.value != None && options.Compression.value != GZIP {
-			return nil, fmt.Errorf("compression values valid are: %d (None) %d (Gzip)", None, GZIP)
-		}
+	if err := options.Validate(); err != nil {
+		return nil, err
 	}
 
 	producer, err := c.coordinator.NewProducer(&ProducerOptions{
@@ -581,12 +707,17 @@ func (c *Client) DeclarePublisher(streamName string, options *ProducerOptions) (
 		ConfirmationTimeOut:  options.ConfirmationTimeOut,
 		ClientProvidedName:   options.ClientProvidedName,
 		Filter:               options.Filter,
+		BackPressure:         options.BackPressure,
+		BackPressureTimeout:  options.BackPressureTimeout,
+		MaxUnConfirmed:       options.MaxUnConfirmed,
+		RateLimiter:          options.RateLimiter,
+		Outbox:               options.Outbox,
 	})
 
 	if err != nil {
 		return nil, err
 	}
-	res := c.internalDeclarePublisher(streamName, producer)
+	res := c.internalDeclarePublisher(ctx, streamName, producer)
 	if res.Err == nil {
 		producer.startPublishTask()
 		producer.startUnconfirmedMessagesTimeOutTask()
@@ -594,7 +725,7 @@ func (c *Client) DeclarePublisher(streamName string, options *ProducerOptions) (
 	return producer, res.Err
 }
 
-func (c *Client) internalDeclarePublisher(streamName string, producer *Producer) responseError {
+func (c *Client) internalDeclarePublisher(ctx context.Context, streamName string, producer *Producer) responseError {
 
 	publisherReferenceSize := 0
 	if producer.options != nil {
@@ -604,7 +735,7 @@ func (c *Client) internalDeclarePublisher(streamName string, producer *Producer)
 	}
 
 	if publisherReferenceSize > 0 {
-		v, err := c.queryPublisherSequence(producer.options.Name, streamName)
+		v, err := c.queryPublisherSequenceCtx(ctx, producer.options.Name, streamName)
 		if err != nil {
 			// if the client can't get the sequence, the function will return an error
 			// because is not able to set the sequence
@@ -629,12 +760,16 @@ func (c *Client) internalDeclarePublisher(streamName string, producer *Producer)
 	}
 
 	writeString(b, streamName)
-	res := c.handleWrite(b.Bytes(), resp)
+	res := c.handleWriteCtx(ctx, b.Bytes(), resp)
 
 	return res
 }
 
 func (c *Client) metaData(streams ...string) *StreamsMetadata {
+	return c.metaDataCtx(context.Background(), streams...)
+}
+
+func (c *Client) metaDataCtx(ctx context.Context, streams ...string) *StreamsMetadata {
 
 	length := 2 + 2 + 4 + 4 // API code, version, correlation id, size of array
 	for _, stream := range streams {
@@ -653,8 +788,9 @@ func (c *Client) metaData(streams ...string) *StreamsMetadata {
 		writeString(b, stream)
 	}
 
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return nil
 	}
 
@@ -664,6 +800,10 @@ func (c *Client) metaData(streams ...string) *StreamsMetadata {
 }
 
 func (c *Client) queryPublisherSequence(publisherReference string, stream string) (int64, error) {
+	return c.queryPublisherSequenceCtx(context.Background(), publisherReference, stream)
+}
+
+func (c *Client) queryPublisherSequenceCtx(ctx context.Context, publisherReference string, stream string) (int64, error) {
 
 	length := 2 + 2 + 4 + 2 + len(publisherReference) + 2 + len(stream)
 	resp := c.coordinator.NewResponse(commandQueryPublisherSequence)
@@ -673,8 +813,9 @@ func (c *Client) queryPublisherSequence(publisherReference string, stream string
 
 	writeString(b, publisherReference)
 	writeString(b, stream)
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return 0, err.Err
 	}
 
@@ -685,8 +826,14 @@ func (c *Client) queryPublisherSequence(publisherReference string, stream string
 }
 
 func (c *Client) BrokerLeader(stream string) (*Broker, error) {
+	return c.BrokerLeaderCtx(context.Background(), stream)
+}
 
-	streamsMetadata := c.metaData(stream)
+func (c *Client) BrokerLeaderCtx(ctx context.Context, stream string) (*Broker, error) {
+	streamsMetadata := c.cachedMetaDataCtx(ctx, stream)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if streamsMetadata == nil {
 		return nil, fmt.Errorf("leader error for stream for stream: %s", stream)
 	}
@@ -708,10 +855,10 @@ func (c *Client) BrokerLeader(stream string) (*Broker, error) {
 		var dnsError *net.DNSError
 		if errors.As(err, &dnsError) {
 			if strings.EqualFold(c.broker.Host, "localhost") {
-				logs.LogWarn("Can't lookup the DNS for %s, error: %s. Trying localhost..", streamMetadata.Leader.Host, err)
+				c.logger.Warn("Can't lookup the DNS. Trying localhost..", "host", streamMetadata.Leader.Host, "error", err)
 				streamMetadata.Leader.Host = "localhost"
 			} else {
-				logs.LogWarn("Can't lookup the DNS for %s, error: %s", streamMetadata.Leader.Host, err)
+				c.logger.Warn("Can't lookup the DNS", "host", streamMetadata.Leader.Host, "error", err)
 			}
 		}
 	}
@@ -720,16 +867,33 @@ func (c *Client) BrokerLeader(stream string) (*Broker, error) {
 }
 
 func (c *Client) StreamExists(stream string) bool {
-	streamsMetadata := c.metaData(stream)
+	exists, _ := c.StreamExistsCtx(context.Background(), stream)
+	return exists
+}
+
+// StreamExistsCtx is like StreamExists but returns the context error
+// when the context is done before the metadata response
+func (c *Client) StreamExistsCtx(ctx context.Context, stream string) (bool, error) {
+	streamsMetadata := c.metaDataCtx(ctx, stream)
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
 	if streamsMetadata == nil {
-		return false
+		return false, nil
 	}
 
 	streamMetadata := streamsMetadata.Get(stream)
-	return streamMetadata.responseCode == responseCodeOk
+	return streamMetadata.responseCode == responseCodeOk, nil
 }
 func (c *Client) BrokerForConsumer(stream string) (*Broker, error) {
-	streamsMetadata := c.metaData(stream)
+	return c.BrokerForConsumerCtx(context.Background(), stream)
+}
+
+func (c *Client) BrokerForConsumerCtx(ctx context.Context, stream string) (*Broker, error) {
+	streamsMetadata := c.cachedMetaDataCtx(ctx, stream)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if streamsMetadata == nil {
 		return nil, fmt.Errorf("leader error for stream: %s", stream)
 	}
@@ -747,7 +911,7 @@ func (c *Client) BrokerForConsumer(stream string) (*Broker, error) {
 	brokers = append(brokers, streamMetadata.Leader)
 	for idx, replica := range streamMetadata.Replicas {
 		if replica == nil {
-			logs.LogWarn("Stream %s replica not ready: %d", stream, idx)
+			c.logger.Warn("Stream replica not ready", "stream", stream, "replica", idx)
 			continue
 		}
 		brokers = append(brokers, replica)
@@ -759,22 +923,29 @@ func (c *Client) BrokerForConsumer(stream string) (*Broker, error) {
 }
 
 func (c *Client) DeclareStream(streamName string, options *StreamOptions) error {
-	if streamName == "" {
-		return fmt.Errorf("stream Name can't be empty")
-	}
+	return c.DeclareStreamCtx(context.Background(), streamName, options)
+}
 
-	resp := c.coordinator.NewResponse(commandCreateStream, streamName)
-	length := 2 + 2 + 4 + 2 + len(streamName) + 4
-	correlationId := resp.correlationid
+func (c *Client) DeclareStreamCtx(ctx context.Context, streamName string, options *StreamOptions) error {
 	if options == nil {
 		options = NewStreamOptions()
 	}
-
 	args, err := options.buildParameters()
 	if err != nil {
-		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return err
 	}
+	return c.declareStreamArgumentsCtx(ctx, streamName, args)
+}
+
+// declareStreamArgumentsCtx declares the stream with the arguments as they are
+func (c *Client) declareStreamArgumentsCtx(ctx context.Context, streamName string, args map[string]string) error {
+	if streamName == "" {
+		return fmt.Errorf("stream Name can't be empty")
+	}
+
+	resp := c.coordinator.NewResponse(commandCreateStream, streamName)
+	length := 2 + 2 + 4 + 2 + len(streamName) + 4
+	correlationId := resp.correlationid
 	for key, element := range args {
 		length = length + 2 + len(key) + 2 + len(element)
 	}
@@ -789,11 +960,15 @@ func (c *Client) DeclareStream(streamName string, options *StreamOptions) error
 		writeString(b, element)
 	}
 
-	return c.handleWrite(b.Bytes(), resp).Err
+	return c.handleWriteCtx(ctx, b.Bytes(), resp).Err
 
 }
 
 func (c *Client) queryOffset(consumerName string, streamName string) (int64, error) {
+	return c.queryOffsetCtx(context.Background(), consumerName, streamName)
+}
+
+func (c *Client) queryOffsetCtx(ctx context.Context, consumerName string, streamName string) (int64, error) {
 	length := 2 + 2 + 4 + 2 + len(consumerName) + 2 + len(streamName)
 
 	resp := c.coordinator.NewResponse(CommandQueryOffset)
@@ -804,8 +979,9 @@ func (c *Client) queryOffset(consumerName string, streamName string) (int64, err
 
 	writeString(b, consumerName)
 	writeString(b, streamName)
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return 0, err.Err
 	}
 
@@ -815,6 +991,12 @@ func (c *Client) queryOffset(consumerName string, streamName string) (int64, err
 }
 
 func (c *Client) DeclareSubscriber(streamName string,
+	messagesHandler MessagesHandler,
+	options *ConsumerOptions) (*Consumer, error) {
+	return c.DeclareSubscriberCtx(context.Background(), streamName, messagesHandler, options)
+}
+
+func (c *Client) DeclareSubscriberCtx(ctx context.Context, streamName string,
 	messagesHandler MessagesHandler,
 	options *ConsumerOptions) (*Consumer, error) {
 	if options == nil {
@@ -873,7 +1055,7 @@ func (c *Client) DeclareSubscriber(streamName string,
 	}
 
 	if options.Offset.isLastConsumed() {
-		lastOffset, err := c.queryOffset(options.ConsumerName, streamName)
+		lastOffset, err := c.queryOffsetCtx(ctx, options.ConsumerName, streamName)
 		switch {
 		case err == nil, errors.Is(err, OffsetNotFoundError):
 			if errors.Is(err, OffsetNotFoundError) {
@@ -964,11 +1146,15 @@ func (c *Client) DeclareSubscriber(streamName string,
 		}
 	}
 
-	err := c.handleWrite(b.Bytes(), resp)
+	err := c.handleWriteCtx(ctx, b.Bytes(), resp)
+	if err.Err == nil {
+		c.metrics.CreditGranted(consumer, int(options.initialCredits))
+	}
 
 	canDispatch := func(offsetMessage *offsetMessage) bool {
 		if !consumer.isActive() {
-			logs.LogDebug("The consumer is not active anymore the message will be skipped, partition %s", streamName)
+			c.logger.Debug("The consumer is not active anymore the message will be skipped", "stream", streamName,
+				"subscription_id", consumer.ID)
 			return false
 		}
 
@@ -1010,6 +1196,10 @@ func (c *Client) DeclareSubscriber(streamName string,
 }
 
 func (c *Client) StreamStats(streamName string) (*StreamStats, error) {
+	return c.StreamStatsCtx(context.Background(), streamName)
+}
+
+func (c *Client) StreamStatsCtx(ctx context.Context, streamName string) (*StreamStats, error) {
 
 	resp := c.coordinator.NewResponse(commandStreamStatus)
 	correlationId := resp.correlationid
@@ -1021,8 +1211,9 @@ func (c *Client) StreamStats(streamName string) (*StreamStats, error) {
 		correlationId)
 	writeString(b, streamName)
 
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return nil, err.Err
 	}
 
@@ -1035,7 +1226,42 @@ func (c *Client) StreamStats(streamName string) (*StreamStats, error) {
 	return newStreamStats(m, streamName), nil
 }
 
+// UpdateSecret sends the new secret (password or token) for the connection.
+// The connection stays open with the new credentials. It requires RabbitMQ 3.13+
+func (c *Client) UpdateSecret(secret string) error {
+	return c.UpdateSecretCtx(context.Background(), secret)
+}
+
+func (c *Client) UpdateSecretCtx(ctx context.Context, secret string) error {
+	if !c.availableFeatures.Is313OrMore() {
+		return UpdateSecretNotSupported
+	}
+
+	resp := c.coordinator.NewResponse(commandUpdateSecret)
+	correlationId := resp.correlationid
+
+	length := 2 + 2 + 4 + 4 + len(secret)
+
+	var b = bytes.NewBuffer(make([]byte, 0, length+4))
+	writeProtocolHeader(b, length, commandUpdateSecret,
+		correlationId)
+	writeInt(b, len(secret))
+	b.WriteString(secret)
+
+	err := c.handleWriteCtx(ctx, b.Bytes(), resp)
+	if err.Err != nil {
+		return err.Err
+	}
+	// the next connections of the client use the new secret
+	c.secret.set(secret)
+	return nil
+}
+
 func (c *Client) DeclareSuperStream(superStream string, options SuperStreamOptions) error {
+	return c.DeclareSuperStreamCtx(context.Background(), superStream, options)
+}
+
+func (c *Client) DeclareSuperStreamCtx(ctx context.Context, superStream string, options SuperStreamOptions) error {
 
 	if !c.availableFeatures.is313OrMore {
 		return fmt.Errorf("declaring super stream via client API not supported, server version is less than 3.13.0")
@@ -1069,11 +1295,16 @@ func (c *Client) DeclareSuperStream(superStream string, options SuperStreamOptio
 		}
 	}
 
+	args, err := options.getArgs()
+	if err != nil {
+		return err
+	}
+
 	length := 2 + 2 + 4 +
 		2 + len(superStream) + 4 +
 		sizeOfStringArray(options.getPartitions(superStream)) + 4 +
 		sizeOfStringArray(options.getBindingKeys()) + 4 +
-		sizeOfMapStringString(options.getArgs())
+		sizeOfMapStringString(args)
 
 	resp := c.coordinator.NewResponse(commandCreateSuperStream, superStream)
 	correlationId := resp.correlationid
@@ -1082,12 +1313,16 @@ func (c *Client) DeclareSuperStream(superStream string, options SuperStreamOptio
 	writeString(b, superStream)
 	writeStringArray(b, options.getPartitions(superStream))
 	writeStringArray(b, options.getBindingKeys())
-	writeMapStringString(b, options.getArgs())
+	writeMapStringString(b, args)
 
-	return c.handleWrite(b.Bytes(), resp).Err
+	return c.handleWriteCtx(ctx, b.Bytes(), resp).Err
 }
 
 func (c *Client) DeleteSuperStream(superStream string) error {
+	return c.DeleteSuperStreamCtx(context.Background(), superStream)
+}
+
+func (c *Client) DeleteSuperStreamCtx(ctx context.Context, superStream string) error {
 
 	if !c.availableFeatures.is313OrMore {
 		return fmt.Errorf("deleting super stream not supported via client API, server version is less than 3.13.0")
@@ -1100,10 +1335,14 @@ func (c *Client) DeleteSuperStream(superStream string) error {
 	writeProtocolHeader(b, length, commandDeleteSuperStream,
 		correlationId)
 	writeString(b, superStream)
-	return c.handleWrite(b.Bytes(), resp).Err
+	return c.handleWriteCtx(ctx, b.Bytes(), resp).Err
 }
 
 func (c *Client) QueryPartitions(superStream string) ([]string, error) {
+	return c.QueryPartitionsCtx(context.Background(), superStream)
+}
+
+func (c *Client) QueryPartitionsCtx(ctx context.Context, superStream string) ([]string, error) {
 	length := 2 + 2 + 4 + 2 + len(superStream)
 	resp := c.coordinator.NewResponse(commandQueryPartition, superStream)
 	correlationId := resp.correlationid
@@ -1111,8 +1350,9 @@ func (c *Client) QueryPartitions(superStream string) ([]string, error) {
 	writeProtocolHeader(b, length, commandQueryPartition,
 		correlationId)
 	writeString(b, superStream)
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return nil, err.Err
 	}
 
@@ -1122,6 +1362,10 @@ func (c *Client) QueryPartitions(superStream string) ([]string, error) {
 }
 
 func (c *Client) queryRoute(superStream string, routingKey string) ([]string, error) {
+	return c.queryRouteCtx(context.Background(), superStream, routingKey)
+}
+
+func (c *Client) queryRouteCtx(ctx context.Context, superStream string, routingKey string) ([]string, error) {
 
 	length := 2 + 2 + 4 + 2 + len(superStream) + 2 + len(routingKey)
 	resp := c.coordinator.NewResponse(commandQueryRoute, superStream)
@@ -1131,8 +1375,9 @@ func (c *Client) queryRoute(superStream string, routingKey string) ([]string, er
 		correlationId)
 	writeString(b, routingKey)
 	writeString(b, superStream)
-	err := c.handleWriteWithResponse(b.Bytes(), resp, false)
+	err := c.handleWriteWithResponseCtx(ctx, b.Bytes(), resp, false)
 	if err.Err != nil {
+		_ = c.coordinator.RemoveResponseById(resp.correlationid)
 		return nil, err.Err
 	}
 
@@ -98,6 +98,47 @@ var _ = Describe("Streaming testEnvironment", func() {
 
 	})
 
+	It("Create Stream with leader locator, initial cluster size, filter size and arguments", func() {
+		streamP := uuid.New().String()
+		options := NewStreamOptions().
+			SetClientLocalLocator().
+			SetInitialClusterSize(1).
+			SetFilterSizeBytes(32).
+			SetArgument("x-custom", "value")
+		args, err := options.buildParameters()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(args).To(Equal(map[string]string{
+			"queue-leader-locator":     LeaderLocatorClientLocal,
+			"initial-cluster-size":     "1",
+			"stream-filter-size-bytes": "32",
+			"x-custom":                 "value",
+		}))
+		Expect(testEnvironment.DeclareStream(streamP, NewStreamOptions().
+			SetBalancedLeaderLocator().
+			SetInitialClusterSize(1).
+			SetFilterSizeBytes(32))).NotTo(HaveOccurred())
+		Expect(testEnvironment.DeleteStream(streamP)).NotTo(HaveOccurred())
+	})
+
+	It("Create Stream with invalid options", func() {
+		for _, options := range []*StreamOptions{
+			NewStreamOptions().SetLeaderLocator("nearest"),
+			NewStreamOptions().SetInitialClusterSize(-1),
+			NewStreamOptions().SetFilterSizeBytes(8),
+			NewStreamOptions().SetFilterSizeBytes(256),
+			NewStreamOptions().SetArgument("max-age", "10s"),
+			NewStreamOptions().SetArgument("x-custom", ""),
+		} {
+			Expect(options.Validate()).To(HaveOccurred())
+			Expect(testEnvironment.DeclareStream(uuid.New().String(), options)).To(HaveOccurred())
+		}
+
+		_, err := NewPartitionsOptions(2).SetFilterSizeBytes(300).getArgs()
+		Expect(err).To(HaveOccurred())
+		_, err = NewBindingsOptions([]string{"a"}).SetArgument("queue-leader-locator", "balanced").getArgs()
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("Create two times Stream", func() {
 		Expect(testEnvironment.DeclareStream(testStreamName, nil)).NotTo(HaveOccurred())
 		err := testEnvironment.DeclareStream(testStreamName, nil)
@@ -163,6 +204,65 @@ var _ = Describe("Streaming testEnvironment", func() {
 		Expect(offset > 0).To(BeTrue())
 	})
 
+	It("Stream Status raw map and typed stats", func() {
+		stats := newStreamStats(map[string]int64{
+			StreamStatsFirstChunkId:     0,
+			StreamStatsLastChunkId:      -1,
+			StreamStatsCommittedChunkId: 42,
+			"new_broker_key":            7,
+		}, "stream")
+		Expect(stats.StreamName()).To(Equal("stream"))
+		raw := stats.Raw()
+		Expect(raw).To(HaveLen(4))
+		Expect(raw).To(HaveKeyWithValue("new_broker_key", int64(7)))
+		raw["new_broker_key"] = 8
+		value, ok := stats.Get("new_broker_key")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal(int64(7)))
+		_, ok = stats.Get("unknown")
+		Expect(ok).To(BeFalse())
+
+		Expect(stats.FirstChunkId()).To(Equal(int64(0)))
+		_, err := stats.LastChunkId()
+		Expect(err).To(MatchError(ContainSubstring("last_chunk_id not found for stream")))
+		_, err = newStreamStats(map[string]int64{}, "stream").FirstChunkId()
+		Expect(err).To(MatchError(ContainSubstring("first_chunk_id not returned by the broker")))
+	})
+
+	It("Stream Status many", func() {
+		streams := []string{uuid.New().String(), uuid.New().String(), uuid.New().String()}
+		for _, stream := range streams {
+			Expect(testEnvironment.DeclareStream(stream, nil)).NotTo(HaveOccurred())
+		}
+		producer, err := testEnvironment.NewProducer(streams[0], nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(producer.BatchSend(CreateArrayMessagesForTesting(10))).NotTo(HaveOccurred())
+		Expect(producer.Close()).NotTo(HaveOccurred())
+
+		Eventually(func() error {
+			results, err := testEnvironment.StreamStatsMany(append(streams, "not-a-stream")...)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(4))
+			for i, stream := range streams {
+				Expect(results[i].Stream).To(Equal(stream))
+				Expect(results[i].Err).NotTo(HaveOccurred())
+			}
+			Expect(results[3].Stream).To(Equal("not-a-stream"))
+			Expect(results[3].Err).To(MatchError(StreamDoesNotExist))
+			_, err = results[1].Stats.CommittedChunkId()
+			Expect(err).To(HaveOccurred())
+			_, err = results[0].Stats.CommittedChunkId()
+			return err
+		}, time.Second*5).Should(Succeed())
+
+		results, err := testEnvironment.StreamStatsMany()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(BeEmpty())
+		for _, stream := range streams {
+			Expect(testEnvironment.DeleteStream(stream)).NotTo(HaveOccurred())
+		}
+	})
+
 	It("Create two times Stream precondition fail", func() {
 		Expect(testEnvironment.DeclareStream(testStreamName, nil)).NotTo(HaveOccurred())
 		err := testEnvironment.DeclareStream(testStreamName,
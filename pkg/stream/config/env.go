@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const EnvPrefix = "RABBITMQ_STREAM_"
+
+// ApplyEnv overrides the configuration with the environment variables.
+// The name of the variable is the prefix plus the path of the field in upper case, for example:
+//
+//	RABBITMQ_STREAM_ENVIRONMENT_URIS=rabbitmq-stream://host1:5552,rabbitmq-stream://host2:5552
+//	RABBITMQ_STREAM_ENVIRONMENT_TLS_CA_CERT_FILE=/etc/rabbitmq/ca.pem
+//	RABBITMQ_STREAM_PRODUCER_BATCH_SIZE=200
+//
+// The lists are comma separated, the maps are comma separated key=value pairs:
+//
+//	RABBITMQ_STREAM_STREAM_ARGUMENTS=max-age=24h,initial-cluster-size=3
+func (c *Config) ApplyEnv() error {
+	return applyEnv(reflect.ValueOf(c).Elem(), strings.TrimSuffix(EnvPrefix, "_"))
+}
+
+func applyEnv(value reflect.Value, prefix string) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		name := prefix + "_" + strings.ToUpper(strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0])
+
+		if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+			section := reflect.New(field.Type().Elem())
+			if !field.IsNil() {
+				section.Elem().Set(field.Elem())
+			}
+			if err := applyEnv(section.Elem(), name); err != nil {
+				return err
+			}
+			if !section.Elem().IsZero() {
+				field.Set(section)
+			}
+			continue
+		}
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidConfig, name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%s is not an integer", raw)
+		}
+		field.SetInt(int64(v))
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s is not true or false", raw)
+		}
+		field.SetBool(v)
+	case reflect.Slice:
+		var values []string
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	case reflect.Map:
+		values := map[string]string{}
+		for _, pair := range strings.Split(raw, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("%s is not a key=value pair", pair)
+			}
+			values[key] = value
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Kind())
+	}
+	return nil
+}
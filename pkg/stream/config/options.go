@@ -0,0 +1,320 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
+)
+
+// EnvironmentOptions validates the environment section and converts it to stream.EnvironmentOptions.
+// The limits of MaxProducersPerClient and MaxConsumersPerClient are checked by stream.NewEnvironment
+func (c *Config) EnvironmentOptions() (*stream.EnvironmentOptions, error) {
+	ec := c.Environment
+	options := stream.NewEnvironmentOptions()
+
+	if len(ec.Uris) > 0 {
+		options.SetUris(ec.Uris)
+	}
+	if ec.Host != "" {
+		options.SetHost(ec.Host)
+	}
+	if ec.Port != 0 {
+		if ec.Port < 0 || ec.Port > 65535 {
+			return nil, invalid("environment.port", "must be between 1 and 65535")
+		}
+		options.SetPort(ec.Port)
+	}
+	if ec.User != "" {
+		options.SetUser(ec.User)
+	}
+	if ec.Password != "" {
+		options.SetPassword(ec.Password)
+	}
+	if ec.Vhost != "" {
+		options.SetVHost(ec.Vhost)
+	}
+	if ec.MaxProducersPerClient != 0 {
+		options.SetMaxProducersPerClient(ec.MaxProducersPerClient)
+	}
+	if ec.MaxConsumersPerClient != 0 {
+		options.SetMaxConsumersPerClient(ec.MaxConsumersPerClient)
+	}
+	if ec.RPCTimeout != "" {
+		timeout, err := parseDuration("environment.rpc_timeout", ec.RPCTimeout)
+		if err != nil {
+			return nil, err
+		}
+		options.SetRPCTimeout(timeout)
+	}
+	switch ec.MetadataCacheTTL {
+	case "":
+	case "disabled":
+		options.SetMetadataCacheTTL(-1)
+	default:
+		ttl, err := parseDuration("environment.metadata_cache_ttl", ec.MetadataCacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		options.SetMetadataCacheTTL(ttl)
+	}
+	if ec.LocatorPoolSize < 0 {
+		return nil, invalid("environment.locator_pool_size", "must be equal or bigger than 0")
+	}
+	options.SetLocatorPoolSize(ec.LocatorPoolSize)
+	if ec.ConnectionName != "" {
+		options.SetConnectionName(ec.ConnectionName)
+	}
+	if ec.RequestedHeartbeat != "" {
+		heartbeat, err := parseDuration("environment.requested_heartbeat", ec.RequestedHeartbeat)
+		if err != nil {
+			return nil, err
+		}
+		options.SetRequestedHeartbeat(heartbeat)
+	}
+	if ec.RequestedMaxFrameSize < 0 || ec.WriteBuffer < 0 || ec.ReadBuffer < 0 {
+		return nil, invalid("environment", "requested_max_frame_size, write_buffer and read_buffer can't be negative")
+	}
+	if ec.RequestedMaxFrameSize > 0 {
+		options.SetRequestedMaxFrameSize(ec.RequestedMaxFrameSize)
+	}
+	if ec.WriteBuffer > 0 {
+		options.SetWriteBuffer(ec.WriteBuffer)
+	}
+	if ec.ReadBuffer > 0 {
+		options.SetReadBuffer(ec.ReadBuffer)
+	}
+	options.SetNoDelay(ec.NoDelay)
+
+	if ec.SaslMechanism != "" {
+		mechanism := strings.ToUpper(ec.SaslMechanism)
+		switch mechanism {
+		case stream.SaslConfigurationPlain, stream.SaslConfigurationExternal, stream.SaslConfigurationScramSha256:
+			options.SetSaslConfiguration(mechanism)
+		default:
+			return nil, invalid("environment.sasl_mechanism", "must be PLAIN, EXTERNAL or SCRAM-SHA-256, value: "+ec.SaslMechanism)
+		}
+	}
+
+	if ec.AddressResolver != "" {
+		host, port, err := net.SplitHostPort(ec.AddressResolver)
+		portNumber, errPort := strconv.Atoi(port)
+		if err != nil || errPort != nil || host == "" {
+			return nil, invalid("environment.address_resolver", "must be host:port, value: "+ec.AddressResolver)
+		}
+		options.SetAddressResolver(stream.AddressResolver{Host: host, Port: portNumber})
+	}
+
+	if ec.TLS != nil {
+		tlsConfig, err := ec.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		if ec.TLS.Enabled {
+			options.IsTLS(true)
+		}
+		options.SetTLSConfig(tlsConfig)
+	}
+	return options, nil
+}
+
+func (t *TLSConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CACertFile != "" {
+		pem, err := os.ReadFile(t.CACertFile)
+		if err != nil {
+			return nil, invalid("environment.tls.ca_cert_file", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, invalid("environment.tls.ca_cert_file", "no PEM certificates in "+t.CACertFile)
+		}
+		config.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, invalid("environment.tls", "cert_file and key_file must be set together")
+		}
+		certificate, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, invalid("environment.tls.cert_file", err.Error())
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// ProducerOptions validates the producer section with the limits of stream.ProducerOptions.Validate
+func (c *Config) ProducerOptions() (*stream.ProducerOptions, error) {
+	pc := c.Producer
+	options := stream.NewProducerOptions()
+	if pc.Name != "" {
+		options.SetProducerName(pc.Name)
+	}
+	if pc.QueueSize != 0 {
+		options.SetQueueSize(pc.QueueSize)
+	}
+	if pc.BatchSize != 0 {
+		options.SetBatchSize(pc.BatchSize)
+	}
+	if pc.BatchPublishingDelay != 0 {
+		options.SetBatchPublishingDelay(pc.BatchPublishingDelay)
+	}
+	if pc.SubEntrySize != 0 {
+		options.SetSubEntrySize(pc.SubEntrySize)
+	}
+	switch strings.ToLower(pc.Compression) {
+	case "", "none":
+	case "gzip":
+		options.SetCompression(stream.Compression{}.Gzip())
+	case "snappy":
+		options.SetCompression(stream.Compression{}.Snappy())
+	case "lz4":
+		options.SetCompression(stream.Compression{}.Lz4())
+	case "zstd":
+		options.SetCompression(stream.Compression{}.Zstd())
+	default:
+		return nil, invalid("producer.compression", "must be none, gzip, snappy, lz4 or zstd, value: "+pc.Compression)
+	}
+	if pc.ConfirmationTimeOut != "" {
+		timeout, err := parseDuration("producer.confirmation_timeout", pc.ConfirmationTimeOut)
+		if err != nil {
+			return nil, err
+		}
+		options.SetConfirmationTimeOut(timeout)
+	}
+	if pc.ClientProvidedName != "" {
+		options.SetClientProvidedName(pc.ClientProvidedName)
+	}
+	if pc.BackPressure != "" || pc.BackPressureTimeout != "" {
+		var timeout time.Duration
+		if pc.BackPressureTimeout != "" {
+			var err error
+			timeout, err = parseDuration("producer.back_pressure_timeout", pc.BackPressureTimeout)
+			if err != nil {
+				return nil, err
+			}
+		}
+		policy := stream.BackPressurePolicy(strings.ToLower(pc.BackPressure))
+		if policy == "" {
+			policy = stream.BackPressureBlock
+		}
+		options.SetBackPressure(policy, timeout)
+	}
+	if pc.MaxUnConfirmed != 0 {
+		options.SetMaxUnConfirmed(pc.MaxUnConfirmed)
+	}
+	if err := options.Validate(); err != nil {
+		return nil, invalid("producer", err.Error())
+	}
+	return options, nil
+}
+
+// ConsumerOptions validates the consumer section and converts it to stream.ConsumerOptions
+func (c *Config) ConsumerOptions() (*stream.ConsumerOptions, error) {
+	cc := c.Consumer
+	options := stream.NewConsumerOptions()
+	if cc.Name != "" {
+		options.SetConsumerName(cc.Name)
+	}
+	if cc.Offset != "" {
+		offset, err := parseOffset(cc.Offset)
+		if err != nil {
+			return nil, err
+		}
+		options.SetOffset(offset)
+	}
+	options.SetCRCCheck(cc.CRCCheck)
+	if cc.InitialCredits != 0 {
+		if cc.InitialCredits < 1 || cc.InitialCredits > math.MaxInt16 {
+			return nil, invalid("consumer.initial_credits", fmt.Sprintf("must be between 1 and %d", math.MaxInt16))
+		}
+		options.SetInitialCredits(int16(cc.InitialCredits))
+	}
+	if cc.ClientProvidedName != "" {
+		options.SetClientProvidedName(cc.ClientProvidedName)
+	}
+	if !cc.AutoCommit && (cc.AutoCommitCount != 0 || cc.AutoCommitFlushInterval != "") {
+		return nil, invalid("consumer", "auto_commit_count and auto_commit_flush_interval require auto_commit")
+	}
+	if cc.AutoCommit {
+		if cc.Name == "" {
+			return nil, invalid("consumer.auto_commit", "requires the consumer name")
+		}
+		strategy := stream.NewAutoCommitStrategy()
+		if cc.AutoCommitCount < 0 {
+			return nil, invalid("consumer.auto_commit_count", "can't be negative")
+		}
+		if cc.AutoCommitCount > 0 {
+			strategy.SetCountBeforeStorage(cc.AutoCommitCount)
+		}
+		if cc.AutoCommitFlushInterval != "" {
+			interval, err := parseDuration("consumer.auto_commit_flush_interval", cc.AutoCommitFlushInterval)
+			if err != nil {
+				return nil, err
+			}
+			strategy.SetFlushInterval(interval)
+		}
+		options.SetAutoCommit(strategy)
+	}
+	return options, nil
+}
+
+// StreamOptions validates the stream section and converts it to stream.StreamOptions
+func (c *Config) StreamOptions() (*stream.StreamOptions, error) {
+	options, err := c.Stream.StreamOptions()
+	if err != nil {
+		return nil, invalid("stream", err.Error())
+	}
+	return options, nil
+}
+
+func parseOffset(value string) (stream.OffsetSpecification, error) {
+	kind, number, hasNumber := strings.Cut(strings.ToLower(value), ":")
+	if !hasNumber {
+		switch kind {
+		case "first":
+			return stream.OffsetSpecification{}.First(), nil
+		case "last":
+			return stream.OffsetSpecification{}.Last(), nil
+		case "next":
+			return stream.OffsetSpecification{}.Next(), nil
+		case "last_consumed":
+			return stream.OffsetSpecification{}.LastConsumed(), nil
+		}
+	} else {
+		n, err := strconv.ParseInt(number, 10, 64)
+		if err == nil && n >= 0 {
+			switch kind {
+			case "offset":
+				return stream.OffsetSpecification{}.Offset(n), nil
+			case "timestamp":
+				return stream.OffsetSpecification{}.Timestamp(n), nil
+			}
+		}
+	}
+	return stream.OffsetSpecification{}, invalid("consumer.offset",
+		"must be first, last, next, last_consumed, offset:<number> or timestamp:<unix milliseconds>, value: "+value)
+}
+
+func parseDuration(field string, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, invalid(field, "must be a positive duration (ex: 30s), value: "+value)
+	}
+	return duration, nil
+}
+
+func invalid(field string, message string) error {
+	return fmt.Errorf("%w: %s %s", ErrInvalidConfig, field, message)
+}
@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
+)
+
+type ConnectionEventType string
+
+const (
+	// ConnectionEventConnected the TCP (and TLS) connection is established
+	ConnectionEventConnected ConnectionEventType = "connected"
+	// ConnectionEventFailed the connection can't be established or opened
+	ConnectionEventFailed ConnectionEventType = "connection failed"
+	// ConnectionEventAuthenticated the SASL authentication succeeded
+	ConnectionEventAuthenticated ConnectionEventType = "authenticated"
+	// ConnectionEventAuthenticationFailed the SASL authentication failed
+	ConnectionEventAuthenticationFailed ConnectionEventType = "authentication failed"
+	// ConnectionEventHeartbeatMissed the broker didn't send data within the heartbeat interval
+	ConnectionEventHeartbeatMissed ConnectionEventType = "heartbeat missed"
+	// ConnectionEventCloseReceived the broker sent a CommandClose
+	ConnectionEventCloseReceived ConnectionEventType = "close received"
+	// ConnectionEventLost the connection was closed without a CommandClose
+	ConnectionEventLost ConnectionEventType = "connection lost"
+	// ConnectionEventClosed the client closed the connection
+	ConnectionEventClosed ConnectionEventType = "closed"
+	// ConnectionEventReaped the closed connection was removed from the environment
+	ConnectionEventReaped ConnectionEventType = "reaped"
+)
+
+// ConnectionEvent is a connection lifecycle event, see Environment.NotifyConnectionEvents
+type ConnectionEvent struct {
+	Type           ConnectionEventType
+	Host           string
+	Port           string
+	ConnectionName string
+	Reason         string
+	Err            error
+}
+
+type ChannelConnectionEvent = <-chan ConnectionEvent
+
+// connectionEventsNotifier dispatches the events of all the clients of an environment.
+// The send is not blocking: when a listener is full the event is dropped,
+// so a slow listener can't block the connections
+type connectionEventsNotifier struct {
+	mutex     *sync.Mutex
+	listeners []chan ConnectionEvent
+	closed    bool
+	logger    logs.Logger
+}
+
+func newConnectionEventsNotifier(logger logs.Logger) *connectionEventsNotifier {
+	return &connectionEventsNotifier{
+		mutex:  &sync.Mutex{},
+		logger: logger,
+	}
+}
+
+func (n *connectionEventsNotifier) register() ChannelConnectionEvent {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	ch := make(chan ConnectionEvent, defaultConnectionEventsBuffer)
+	if n.closed {
+		close(ch)
+		return ch
+	}
+	n.listeners = append(n.listeners, ch)
+	return ch
+}
+
+func (n *connectionEventsNotifier) notify(event ConnectionEvent) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.closed {
+		return
+	}
+	for _, listener := range n.listeners {
+		select {
+		case listener <- event:
+		default:
+			n.logger.Warn("Connection events listener is full, event dropped",
+				"event", event.Type, "connection_name", event.ConnectionName)
+		}
+	}
+}
+
+func (n *connectionEventsNotifier) close() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.closed {
+		return
+	}
+	n.closed = true
+	for _, listener := range n.listeners {
+		close(listener)
+	}
+	n.listeners = nil
+}
+
+func (c *Client) notifyConnectionEvent(eventType ConnectionEventType, reason string, err error) {
+	if c.connectionEvents == nil {
+		return
+	}
+	host, port := c.broker.Host, c.broker.Port
+	if u, errUri := url.Parse(c.broker.GetUri()); errUri == nil {
+		host, port = u.Hostname(), u.Port()
+	}
+	c.connectionEvents.notify(ConnectionEvent{
+		Type:           eventType,
+		Host:           host,
+		Port:           port,
+		ConnectionName: c.getConnectionName(),
+		Reason:         reason,
+		Err:            err,
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
-	logs "github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
 	"sync"
 	"time"
 )
@@ -304,7 +303,7 @@ func (c *Client) credit(subscriptionId byte, credit int16) {
 	writeShort(b, credit)
 	err := c.socket.writeAndFlush(b.Bytes())
 	if err != nil {
-		logs.LogWarn("credit error:%s", err)
+		c.logger.Warn("Credit error", "subscription_id", subscriptionId, "error", err)
 	}
 }
 
@@ -342,7 +341,7 @@ func (consumer *Consumer) Close() error {
 	})
 
 	if errC != nil {
-		logs.LogWarn("Error during remove consumer id:%s", errC)
+		consumer.options.client.logger.Warn("Error during remove consumer", "subscription_id", consumer.ID, "error", errC)
 
 	}
 
@@ -369,7 +368,8 @@ func (consumer *Consumer) cacheStoreOffset() {
 
 		err := consumer.internalStoreOffset()
 		if err != nil {
-			logs.LogError("cache Store Offset error : %s", err)
+			consumer.options.client.logger.Error("Cache Store Offset error", "consumer", consumer.GetName(),
+				"stream", consumer.GetStreamName(), "error", err)
 		}
 	}
 }
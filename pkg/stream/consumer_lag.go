@@ -0,0 +1,321 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConsumerLag is how far a consumer is behind the stream.
+// CommittedChunkId is the offset of the first message of the last committed chunk (see StreamStats),
+// so Lag is an approximation: it is 0 when the consumer is in the last chunk
+type ConsumerLag struct {
+	Consumer         string
+	Stream           string
+	Offset           int64 // the stored offset, or the in-memory offset of a live Consumer
+	CommittedChunkId int64 // -1 when the stream is empty
+	Lag              int64
+}
+
+func newConsumerLag(consumerName string, streamName string, offset int64, stats *StreamStats) *ConsumerLag {
+	lag := &ConsumerLag{Consumer: consumerName, Stream: streamName, Offset: offset, CommittedChunkId: -1}
+	if committed, err := stats.CommittedChunkId(); err == nil {
+		lag.CommittedChunkId = committed
+		if committed > offset {
+			lag.Lag = committed - offset
+		}
+	}
+	return lag
+}
+
+// ConsumerLag returns the lag of the consumer from its stored offset.
+// It returns OffsetNotFoundError if the consumer never stored an offset
+func (env *Environment) ConsumerLag(consumerName string, streamName string) (*ConsumerLag, error) {
+	return env.ConsumerLagCtx(context.Background(), consumerName, streamName)
+}
+
+// ConsumerLagCtx is like ConsumerLag but it returns ctx.Err() when the context is done
+func (env *Environment) ConsumerLagCtx(ctx context.Context, consumerName string, streamName string) (*ConsumerLag, error) {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
+	if err != nil {
+		return nil, err
+	}
+	return consumerLagCtx(ctx, client, consumerName, streamName)
+}
+
+// the offset and the stats are read with the same locator connection
+func consumerLagCtx(ctx context.Context, client *Client, consumerName string, streamName string) (*ConsumerLag, error) {
+	offset, err := client.queryOffsetCtx(ctx, consumerName, streamName)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := client.StreamStatsCtx(ctx, streamName)
+	if err != nil {
+		return nil, err
+	}
+	return newConsumerLag(consumerName, streamName, offset, stats), nil
+}
+
+// ConsumerLagOf returns the lag of a live consumer from its in-memory offset (see Consumer.GetOffset),
+// the consumer doesn't need to store the offset
+func (env *Environment) ConsumerLagOf(consumer *Consumer) (*ConsumerLag, error) {
+	return env.ConsumerLagOfCtx(context.Background(), consumer)
+}
+
+// ConsumerLagOfCtx is like ConsumerLagOf but it returns ctx.Err() when the context is done
+func (env *Environment) ConsumerLagOfCtx(ctx context.Context, consumer *Consumer) (*ConsumerLag, error) {
+	stats, err := env.StreamStatsCtx(ctx, consumer.GetStreamName())
+	if err != nil {
+		return nil, err
+	}
+	return newConsumerLag(consumer.GetName(), consumer.GetStreamName(), consumer.GetOffset(), stats), nil
+}
+
+// SuperStreamConsumerLag is the lag of a consumer over the partitions of a super stream
+type SuperStreamConsumerLag struct {
+	Consumer    string
+	SuperStream string
+	Partitions  []*ConsumerLag // the partitions where the consumer stored an offset
+	// PartitionsWithoutOffset are the partitions where the consumer never stored an offset, they are not in Lag
+	PartitionsWithoutOffset []string
+	Lag                     int64 // the sum of the partitions lag
+}
+
+func (env *Environment) SuperStreamConsumerLag(consumerName string, superStream string) (*SuperStreamConsumerLag, error) {
+	return env.SuperStreamConsumerLagCtx(context.Background(), consumerName, superStream)
+}
+
+// SuperStreamConsumerLagCtx aggregates the lag of the partitions returned by QueryPartitions.
+// It returns ctx.Err() when the context is done
+func (env *Environment) SuperStreamConsumerLagCtx(ctx context.Context, consumerName string, superStream string) (*SuperStreamConsumerLag, error) {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := client.QueryPartitionsCtx(ctx, superStream)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SuperStreamConsumerLag{Consumer: consumerName, SuperStream: superStream}
+	for _, partition := range partitions {
+		lag, err := consumerLagCtx(ctx, client, consumerName, partition)
+		if errors.Is(err, OffsetNotFoundError) {
+			result.PartitionsWithoutOffset = append(result.PartitionsWithoutOffset, partition)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("partition %s: %w", partition, err)
+		}
+		result.Partitions = append(result.Partitions, lag)
+		result.Lag += lag.Lag
+	}
+	return result, nil
+}
+
+type LagMonitorOptions struct {
+	Interval     time.Duration // default 10 seconds
+	Threshold    int64         // a lag bigger than Threshold is a breach, 0 disables the breaches
+	OnlyBreaches bool          // send only the breaches and the errors
+	BufferSize   int           // size of the events channel, default 100
+}
+
+func NewLagMonitorOptions() *LagMonitorOptions {
+	return &LagMonitorOptions{
+		Interval:   defaultLagMonitorInterval,
+		BufferSize: defaultLagMonitorBuffer,
+	}
+}
+
+func (o *LagMonitorOptions) SetInterval(interval time.Duration) *LagMonitorOptions {
+	o.Interval = interval
+	return o
+}
+
+func (o *LagMonitorOptions) SetThreshold(threshold int64) *LagMonitorOptions {
+	o.Threshold = threshold
+	return o
+}
+
+func (o *LagMonitorOptions) SetOnlyBreaches(onlyBreaches bool) *LagMonitorOptions {
+	o.OnlyBreaches = onlyBreaches
+	return o
+}
+
+func (o *LagMonitorOptions) SetBufferSize(size int) *LagMonitorOptions {
+	o.BufferSize = size
+	return o
+}
+
+// LagEvent is a lag sample sent by the LagMonitor.
+// Err is set when the lag can't be read, in this case Lag has only the consumer and the stream
+type LagEvent struct {
+	Time     time.Time
+	Lag      ConsumerLag
+	Breached bool
+	Err      error
+}
+
+type lagTarget struct {
+	consumerName string
+	stream       string
+	superStream  bool
+	consumer     *Consumer
+}
+
+// LagMonitor polls the lag of a set of consumers at an interval and sends the samples on Events().
+// The super streams send a sample for each partition.
+// The send is not blocking: when the channel is full the event is dropped
+type LagMonitor struct {
+	env     *Environment
+	options *LagMonitorOptions
+	mutex   *sync.Mutex
+	targets []lagTarget
+	events  chan LagEvent
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewLagMonitor starts a monitor, add the consumers with Watch, WatchSuperStream and WatchConsumer.
+// Close stops it and closes the events channel
+func (env *Environment) NewLagMonitor(options *LagMonitorOptions) (*LagMonitor, error) {
+	if options == nil {
+		options = NewLagMonitorOptions()
+	}
+	if options.Interval == 0 {
+		options.Interval = defaultLagMonitorInterval
+	}
+	if options.BufferSize == 0 {
+		options.BufferSize = defaultLagMonitorBuffer
+	}
+	if options.Interval < 0 {
+		return nil, fmt.Errorf("LagMonitorOptions Interval must be positive")
+	}
+	if options.Threshold < 0 || options.BufferSize < 0 {
+		return nil, fmt.Errorf("LagMonitorOptions Threshold and BufferSize can't be negative")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	monitor := &LagMonitor{
+		env:     env,
+		options: options,
+		mutex:   &sync.Mutex{},
+		events:  make(chan LagEvent, options.BufferSize),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go monitor.run(ctx)
+	return monitor, nil
+}
+
+// Watch monitors the stored offset of a named consumer
+func (m *LagMonitor) Watch(consumerName string, streamName string) *LagMonitor {
+	return m.add(lagTarget{consumerName: consumerName, stream: streamName})
+}
+
+// WatchSuperStream monitors the stored offsets of a named consumer on all the partitions.
+// The partitions where the consumer never stored an offset send an event with OffsetNotFoundError
+func (m *LagMonitor) WatchSuperStream(consumerName string, superStream string) *LagMonitor {
+	return m.add(lagTarget{consumerName: consumerName, stream: superStream, superStream: true})
+}
+
+// WatchConsumer monitors the in-memory offset of a live consumer until it is closed
+func (m *LagMonitor) WatchConsumer(consumer *Consumer) *LagMonitor {
+	return m.add(lagTarget{consumerName: consumer.GetName(), stream: consumer.GetStreamName(), consumer: consumer})
+}
+
+func (m *LagMonitor) add(target lagTarget) *LagMonitor {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.targets = append(m.targets, target)
+	return m
+}
+
+func (m *LagMonitor) Events() <-chan LagEvent {
+	return m.events
+}
+
+func (m *LagMonitor) Close() {
+	m.cancel()
+	<-m.done
+}
+
+func (m *LagMonitor) run(ctx context.Context) {
+	defer close(m.done)
+	defer close(m.events)
+	ticker := time.NewTicker(m.options.Interval)
+	defer ticker.Stop()
+	for {
+		m.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *LagMonitor) poll(ctx context.Context) {
+	m.mutex.Lock()
+	targets := make([]lagTarget, 0, len(m.targets))
+	for _, target := range m.targets {
+		// the closed consumers are removed
+		if target.consumer == nil || target.consumer.getStatus() == open {
+			targets = append(targets, target)
+		}
+	}
+	m.targets = targets
+	m.mutex.Unlock()
+
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case target.consumer != nil:
+			lag, err := m.env.ConsumerLagOfCtx(ctx, target.consumer)
+			m.send(target, lag, err)
+		case target.superStream:
+			lag, err := m.env.SuperStreamConsumerLagCtx(ctx, target.consumerName, target.stream)
+			if err != nil {
+				m.send(target, nil, err)
+				continue
+			}
+			for _, partitionLag := range lag.Partitions {
+				m.send(target, partitionLag, nil)
+			}
+			for _, partition := range lag.PartitionsWithoutOffset {
+				m.send(lagTarget{consumerName: target.consumerName, stream: partition}, nil, OffsetNotFoundError)
+			}
+		default:
+			lag, err := m.env.ConsumerLagCtx(ctx, target.consumerName, target.stream)
+			m.send(target, lag, err)
+		}
+	}
+}
+
+func (m *LagMonitor) send(target lagTarget, lag *ConsumerLag, err error) {
+	// the monitor is closing
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	event := LagEvent{Time: time.Now(), Err: err}
+	if lag != nil {
+		event.Lag = *lag
+		event.Breached = m.options.Threshold > 0 && lag.Lag > m.options.Threshold
+	} else {
+		event.Lag = ConsumerLag{Consumer: target.consumerName, Stream: target.stream}
+	}
+	if m.options.OnlyBreaches && !event.Breached && event.Err == nil {
+		return
+	}
+	select {
+	case m.events <- event:
+	default:
+		m.env.options.Logger.Warn("Lag monitor events channel is full, event dropped",
+			"consumer", event.Lag.Consumer, "stream", event.Lag.Stream)
+	}
+}
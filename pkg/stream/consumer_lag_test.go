@@ -0,0 +1,185 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
+)
+
+var _ = Describe("Consumer lag", func() {
+	var (
+		env        *Environment
+		streamName string
+	)
+
+	// waitForChunks sends 3 batches and waits until the last chunk is committed
+	waitForChunks := func(stream string) int64 {
+		for i := 0; i < 3; i++ {
+			SendMessages(env, stream)
+		}
+		var committed int64
+		Eventually(func() int64 {
+			stats, err := env.StreamStats(stream)
+			Expect(err).NotTo(HaveOccurred())
+			committed, _ = stats.CommittedChunkId()
+			return committed
+		}, time.Second*5).Should(BeNumerically(">=", 60))
+		return committed
+	}
+
+	BeforeEach(func() {
+		var err error
+		env, err = NewEnvironment(nil)
+		Expect(err).NotTo(HaveOccurred())
+		streamName = uuid.New().String()
+		Expect(env.DeclareStream(streamName, nil)).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(env.DeleteStream(streamName)).NotTo(HaveOccurred())
+		Expect(env.Close()).NotTo(HaveOccurred())
+	})
+
+	It("Named consumer lag", func() {
+		committed := waitForChunks(streamName)
+		_, err := env.ConsumerLag("lag-consumer", streamName)
+		Expect(errors.Is(err, OffsetNotFoundError)).To(BeTrue())
+
+		consumer, err := env.NewConsumer(streamName, func(_ ConsumerContext, _ *amqp.Message) {},
+			NewConsumerOptions().SetConsumerName("lag-consumer").SetOffset(OffsetSpecification{}.First()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(consumer.StoreCustomOffset(10)).NotTo(HaveOccurred())
+		Expect(consumer.Close()).NotTo(HaveOccurred())
+
+		Eventually(func() int64 {
+			lag, err := env.ConsumerLag("lag-consumer", streamName)
+			if err != nil {
+				return -1
+			}
+			return lag.Offset
+		}, time.Second*5).Should(Equal(int64(10)))
+		lag, err := env.ConsumerLag("lag-consumer", streamName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lag.Consumer).To(Equal("lag-consumer"))
+		Expect(lag.Stream).To(Equal(streamName))
+		Expect(lag.CommittedChunkId).To(Equal(committed))
+		Expect(lag.Lag).To(Equal(committed - 10))
+	})
+
+	It("Live consumer lag", func() {
+		committed := waitForChunks(streamName)
+		var received int32
+		consumer, err := env.NewConsumer(streamName, func(_ ConsumerContext, _ *amqp.Message) {
+			atomic.AddInt32(&received, 1)
+		}, NewConsumerOptions().SetOffset(OffsetSpecification{}.First()))
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() int32 { return atomic.LoadInt32(&received) }, time.Second*5).Should(Equal(int32(90)))
+
+		lag, err := env.ConsumerLagOf(consumer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lag.CommittedChunkId).To(Equal(committed))
+		Expect(lag.Offset).To(Equal(int64(89)))
+		Expect(lag.Lag).To(Equal(int64(0)))
+		Expect(consumer.Close()).NotTo(HaveOccurred())
+	})
+
+	It("Super stream consumer lag", Label("super-stream"), func() {
+		superStream := fmt.Sprintf("lag-super-stream-%s", uuid.New().String())
+		Expect(env.DeclareSuperStream(superStream, NewPartitionsOptions(2))).NotTo(HaveOccurred())
+		partition := superStream + "-0"
+		committed := waitForChunks(partition)
+
+		consumer, err := env.NewConsumer(partition, func(_ ConsumerContext, _ *amqp.Message) {},
+			NewConsumerOptions().SetConsumerName("lag-consumer"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(consumer.StoreCustomOffset(0)).NotTo(HaveOccurred())
+		Expect(consumer.Close()).NotTo(HaveOccurred())
+
+		Eventually(func() int {
+			lag, err := env.SuperStreamConsumerLag("lag-consumer", superStream)
+			Expect(err).NotTo(HaveOccurred())
+			return len(lag.Partitions)
+		}, time.Second*5).Should(Equal(1))
+		lag, err := env.SuperStreamConsumerLag("lag-consumer", superStream)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lag.Partitions[0].Stream).To(Equal(partition))
+		Expect(lag.PartitionsWithoutOffset).To(Equal([]string{superStream + "-1"}))
+		Expect(lag.Lag).To(Equal(committed))
+
+		monitor, err := env.NewLagMonitor(NewLagMonitorOptions().SetInterval(100 * time.Millisecond))
+		Expect(err).NotTo(HaveOccurred())
+		monitor.WatchSuperStream("lag-consumer", superStream)
+		var withoutOffset LagEvent
+		Eventually(func() error {
+			select {
+			case event := <-monitor.Events():
+				if event.Err != nil {
+					withoutOffset = event
+				}
+			default:
+			}
+			return withoutOffset.Err
+		}, time.Second*5).Should(MatchError(OffsetNotFoundError))
+		Expect(withoutOffset.Lag.Consumer).To(Equal("lag-consumer"))
+		Expect(withoutOffset.Lag.Stream).To(Equal(superStream + "-1"))
+		monitor.Close()
+		Expect(env.DeleteSuperStream(superStream)).NotTo(HaveOccurred())
+	})
+
+	It("Lag monitor", func() {
+		_, err := env.NewLagMonitor(NewLagMonitorOptions().SetInterval(-time.Second))
+		Expect(err).To(HaveOccurred())
+		_, err = env.NewLagMonitor(NewLagMonitorOptions().SetThreshold(-1))
+		Expect(err).To(HaveOccurred())
+		// zero is the default
+		defaults, err := env.NewLagMonitor(&LagMonitorOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaults.options.Interval).To(Equal(defaultLagMonitorInterval))
+		Expect(cap(defaults.events)).To(Equal(defaultLagMonitorBuffer))
+		defaults.Close()
+
+		committed := waitForChunks(streamName)
+		consumer, err := env.NewConsumer(streamName, func(_ ConsumerContext, _ *amqp.Message) {},
+			NewConsumerOptions().SetConsumerName("lag-consumer").SetOffset(OffsetSpecification{}.First()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(consumer.StoreCustomOffset(0)).NotTo(HaveOccurred())
+
+		monitor, err := env.NewLagMonitor(NewLagMonitorOptions().
+			SetInterval(100 * time.Millisecond).
+			SetThreshold(committed - 1).
+			SetOnlyBreaches(true))
+		Expect(err).NotTo(HaveOccurred())
+		monitor.Watch("lag-consumer", streamName).Watch("unknown-consumer", streamName)
+
+		var breach, failure LagEvent
+		Eventually(func() bool {
+			select {
+			case event := <-monitor.Events():
+				if event.Err != nil {
+					failure = event
+				} else {
+					breach = event
+				}
+			default:
+			}
+			return failure.Err != nil && breach.Breached
+		}, time.Second*5).Should(BeTrue())
+		Expect(breach.Lag.Consumer).To(Equal("lag-consumer"))
+		Expect(breach.Lag.Lag).To(Equal(committed))
+		Expect(failure.Lag.Consumer).To(Equal("unknown-consumer"))
+		Expect(errors.Is(failure.Err, OffsetNotFoundError)).To(BeTrue())
+
+		monitor.Close()
+		Eventually(func() bool {
+			_, ok := <-monitor.Events()
+			return ok
+		}, time.Second).Should(BeFalse())
+		Expect(consumer.Close()).NotTo(HaveOccurred())
+	})
+})
@@ -69,6 +69,7 @@ func (coordinator *Coordinator) NewProducer(
 		mutexPending:        &sync.Mutex{},
 		unConfirmedMessages: map[int64]*ConfirmationStatus{},
 		status:              open,
+		done:                make(chan struct{}),
 		messageSequenceCh:   make(chan messageSequence, size),
 		pendingMessages: pendingMessagesSequence{
 			messages: make([]messageSequence, 0),
@@ -91,7 +92,9 @@ func (coordinator *Coordinator) RemoveConsumerById(id interface{}, reason Event)
 		close(closeHandler)
 	}
 
-	return coordinator.removeById(id, coordinator.consumers)
+	err = coordinator.removeById(id, coordinator.consumers)
+	consumer.reportClosed()
+	return err
 }
 func (coordinator *Coordinator) RemoveProducerById(id uint8, reason Event) error {
 
@@ -111,7 +114,10 @@ func (coordinator *Coordinator) RemoveProducerById(id uint8, reason Event) error
 		producer.closeHandler <- reason
 	}
 
-	return coordinator.removeById(id, coordinator.producers)
+	err = coordinator.removeById(id, coordinator.producers)
+	producer.signalDone()
+	producer.reportClosed()
+	return err
 }
 
 func (coordinator *Coordinator) RemoveResponseById(id interface{}) error {
@@ -305,3 +311,25 @@ func (coordinator *Coordinator) Producers() map[interface{}]interface{} {
 	defer coordinator.mutex.Unlock()
 	return coordinator.producers
 }
+
+// producersList returns a copy of the producers, safe to use without the coordinator lock
+func (coordinator *Coordinator) producersList() []*Producer {
+	coordinator.mutex.Lock()
+	defer coordinator.mutex.Unlock()
+	result := make([]*Producer, 0, len(coordinator.producers))
+	for _, producer := range coordinator.producers {
+		result = append(result, producer.(*Producer))
+	}
+	return result
+}
+
+// consumersList returns a copy of the consumers, safe to use without the coordinator lock
+func (coordinator *Coordinator) consumersList() []*Consumer {
+	coordinator.mutex.Lock()
+	defer coordinator.mutex.Unlock()
+	result := make([]*Consumer, 0, len(coordinator.consumers))
+	for _, consumer := range coordinator.consumers {
+		result = append(result, consumer.(*Consumer))
+	}
+	return result
+}
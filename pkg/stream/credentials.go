@@ -0,0 +1,118 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// CredentialsProvider returns the secret (password or token) for the connections
+// and how long to wait before asking for the next one.
+// refreshIn should be shorter than the secret validity, so the connections are updated
+// before the secret expires. A refreshIn <= 0 stops the rotation.
+type CredentialsProvider func() (secret string, refreshIn time.Duration, err error)
+
+// secretHolder keeps the current secret (password or token) of the connections.
+// The environment shares it with all its clients, so a rotation doesn't change
+// the Brokers of the options and the next connections are dialed with the last secret
+type secretHolder struct {
+	value atomic.Value
+}
+
+func newSecretHolder() *secretHolder {
+	return &secretHolder{}
+}
+
+func (s *secretHolder) set(secret string) {
+	s.value.Store(secret)
+}
+
+// get returns false when no secret is set, the password of the broker is used in that case
+func (s *secretHolder) get() (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	secret, ok := s.value.Load().(string)
+	return secret, ok
+}
+
+// refreshCredentials calls the CredentialsProvider and pushes the new secret to
+// all the connections until the context is done
+func (env *Environment) refreshCredentials(ctx context.Context, refreshIn time.Duration) {
+	for refreshIn > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(refreshIn):
+		}
+
+		secret, next, err := env.options.CredentialsProvider()
+		if err != nil {
+			env.options.Logger.Warn("Can't get the credentials from the CredentialsProvider",
+				"retry_in", defaultCredentialsRetryInterval, "error", err)
+			refreshIn = defaultCredentialsRetryInterval
+			continue
+		}
+		if err := env.UpdateSecretCtx(ctx, secret); err != nil {
+			env.options.Logger.Warn("Error updating the secret", "error", err)
+		}
+		refreshIn = next
+	}
+}
+
+// UpdateSecret pushes the new secret to all the connections of the environment:
+// locators, producers and consumers. The new connections use the new secret as well.
+// It requires RabbitMQ 3.13+
+func (env *Environment) UpdateSecret(secret string) error {
+	return env.UpdateSecretCtx(context.Background(), secret)
+}
+
+// UpdateSecretCtx is like UpdateSecret but it returns ctx.Err() when the context is done
+func (env *Environment) UpdateSecretCtx(ctx context.Context, secret string) error {
+	env.secret.set(secret)
+
+	clients := env.connectedClients()
+	var lastError error
+	failed := 0
+	for _, client := range clients {
+		if err := client.UpdateSecretCtx(ctx, secret); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			env.options.Logger.Warn("Can't update the secret for the connection",
+				"connection_name", client.getConnectionName(), "error", err)
+			lastError = err
+			failed++
+		}
+	}
+	if lastError != nil {
+		return fmt.Errorf("can't update the secret for %d of %d connections, last error: %w",
+			failed, len(clients), lastError)
+	}
+	return nil
+}
+
+// connectedClients returns the open clients managed by the environment
+func (env *Environment) connectedClients() []*Client {
+	var clients []*Client
+	if env.locators != nil {
+		env.locators.mutex.Lock()
+		clients = append(clients, env.locators.locators...)
+		env.locators.mutex.Unlock()
+	}
+	for _, coordinator := range env.producers.getCoordinators() {
+		clients = append(clients, coordinator.clients()...)
+	}
+	for _, coordinator := range env.consumers.getCoordinators() {
+		clients = append(clients, coordinator.clients()...)
+	}
+
+	var result []*Client
+	for _, client := range clients {
+		if client != nil && client.socket.isOpen() {
+			result = append(result, client)
+		}
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// StreamConfigReader reads the arguments of an existing stream. The stream protocol doesn't expose them,
+// so EnsureStream uses the reader (when set) to describe the differences. The keys are the stream arguments
+// without the x- prefix, ex: max-age, max-length-bytes. See NewManagementStreamConfigReader
+type StreamConfigReader func(ctx context.Context, vhost string, streamName string) (map[string]string, error)
+
+// StreamArgumentMismatch is an argument with a different value in the existing stream.
+// Actual is empty when the argument is not set in the existing stream
+type StreamArgumentMismatch struct {
+	Key      string
+	Expected string
+	Actual   string
+}
+
+// StreamConfigMismatch is returned by EnsureStream when the stream exists with different arguments.
+// errors.Is(err, PreconditionFailed) is true
+type StreamConfigMismatch struct {
+	Stream   string
+	Expected map[string]string // the arguments sent by the client
+	// Actual are the arguments of the existing stream, nil without StreamConfigReader or when ReadError is not nil
+	Actual     map[string]string
+	Mismatches []StreamArgumentMismatch // empty when Actual is nil
+	ReadError  error
+}
+
+func (e *StreamConfigMismatch) Error() string {
+	if e.Actual == nil {
+		message := fmt.Sprintf("stream %s exists with different arguments, expected: %s", e.Stream, formatArguments(e.Expected))
+		if e.ReadError != nil {
+			message += fmt.Sprintf(" (can't read the current arguments: %s)", e.ReadError)
+		}
+		return message
+	}
+	differences := make([]string, 0, len(e.Mismatches))
+	for _, mismatch := range e.Mismatches {
+		differences = append(differences, fmt.Sprintf("%s expected %q actual %q", mismatch.Key, mismatch.Expected, mismatch.Actual))
+	}
+	return fmt.Sprintf("stream %s exists with different arguments: %s", e.Stream, strings.Join(differences, ", "))
+}
+
+func (e *StreamConfigMismatch) Unwrap() error {
+	return PreconditionFailed
+}
+
+func (env *Environment) EnsureStream(streamName string, options *StreamOptions) error {
+	return env.EnsureStreamCtx(context.Background(), streamName, options)
+}
+
+// EnsureStreamCtx declares the stream if it doesn't exist. It returns nil if the stream exists with the same
+// arguments and a *StreamConfigMismatch error if the arguments are different.
+// With a StreamConfigReader (see EnvironmentOptions.SetStreamConfigReader) the current arguments are read back:
+// the error lists the differences, and the arguments not checked by the broker (ex: the leader locator)
+// are compared as well
+func (env *Environment) EnsureStreamCtx(ctx context.Context, streamName string, options *StreamOptions) error {
+	if options == nil {
+		options = NewStreamOptions()
+	}
+	expected, err := options.buildParameters()
+	if err != nil {
+		return err
+	}
+	return env.ensureStreamArgumentsCtx(ctx, streamName, expected)
+}
+
+// ensureStreamArgumentsCtx is EnsureStreamCtx with the arguments as they are sent to the broker.
+// The super stream partitions are compared with the arguments of the super stream declaration
+func (env *Environment) ensureStreamArgumentsCtx(ctx context.Context, streamName string, expected map[string]string) error {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
+	if err != nil {
+		return err
+	}
+
+	err = client.declareStreamArgumentsCtx(ctx, streamName, expected)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, StreamAlreadyExists):
+		if env.options.StreamConfigReader == nil {
+			return nil
+		}
+		actual, readError := env.options.StreamConfigReader(ctx, client.broker.Vhost, streamName)
+		if readError != nil {
+			env.options.Logger.Warn("Can't read the arguments of the stream", "stream", streamName, "error", readError)
+			return nil
+		}
+		// the broker doesn't store all the arguments, only the ones returned are compared
+		mismatches := compareArguments(expected, actual, false)
+		if len(mismatches) == 0 {
+			return nil
+		}
+		return &StreamConfigMismatch{Stream: streamName, Expected: expected, Actual: actual, Mismatches: mismatches}
+	case errors.Is(err, PreconditionFailed):
+		mismatch := &StreamConfigMismatch{Stream: streamName, Expected: expected}
+		if env.options.StreamConfigReader != nil {
+			actual, readError := env.options.StreamConfigReader(ctx, client.broker.Vhost, streamName)
+			if readError != nil {
+				mismatch.ReadError = readError
+			} else {
+				mismatch.Actual = actual
+				mismatch.Mismatches = compareArguments(expected, actual, true)
+			}
+		}
+		return mismatch
+	default:
+		return err
+	}
+}
+
+// compareArguments returns the expected arguments with a different value, sorted by key.
+// When missingIsMismatch is false, the arguments not in actual are ignored
+func compareArguments(expected map[string]string, actual map[string]string, missingIsMismatch bool) []StreamArgumentMismatch {
+	var mismatches []StreamArgumentMismatch
+	for key, value := range expected {
+		actualValue, ok := actual[key]
+		if !ok && !missingIsMismatch {
+			continue
+		}
+		if actualValue != value {
+			mismatches = append(mismatches, StreamArgumentMismatch{Key: key, Expected: value, Actual: actualValue})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Key < mismatches[j].Key
+	})
+	return mismatches
+}
+
+func formatArguments(arguments map[string]string) string {
+	keys := make([]string, 0, len(arguments))
+	for key := range arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, fmt.Sprintf("%s=%s", key, arguments[key]))
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// NewManagementStreamConfigReader reads the stream arguments with the HTTP API of the management plugin,
+// baseUrl is for example http://localhost:15672
+func NewManagementStreamConfigReader(baseUrl string, user string, password string) StreamConfigReader {
+	return func(ctx context.Context, vhost string, streamName string) (map[string]string, error) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			fmt.Sprintf("%s/api/queues/%s/%s", strings.TrimSuffix(baseUrl, "/"),
+				url.PathEscape(vhost), url.PathEscape(streamName)), nil)
+		if err != nil {
+			return nil, err
+		}
+		request.SetBasicAuth(user, password)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("management API returned %s", response.Status)
+		}
+
+		var queue struct {
+			Arguments map[string]json.RawMessage `json:"arguments"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&queue); err != nil {
+			return nil, err
+		}
+		arguments := make(map[string]string, len(queue.Arguments))
+		for key, raw := range queue.Arguments {
+			var value string
+			// the numbers are not quoted
+			if err := json.Unmarshal(raw, &value); err != nil {
+				value = string(raw)
+			}
+			arguments[strings.TrimPrefix(key, "x-")] = value
+		}
+		return arguments, nil
+	}
+}
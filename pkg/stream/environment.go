@@ -1,6 +1,7 @@
 package stream
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -18,41 +19,128 @@ type Environment struct {
 	producers *producersEnvironment
 	consumers *consumersEnvironment
 	options   *EnvironmentOptions
+	locators  *locatorPool
 	closed    bool
+
+	stopCredentialsRefresh context.CancelFunc
+	shuttingDown           int32
+	clientWiring
+}
+
+// clientWiring is the state of the environment shared by its clients
+type clientWiring struct {
+	connectionEvents *connectionEventsNotifier
+	logger           logs.Logger
+	metrics          MetricsCollector
+	// metadataCache is wired only on the locators, see wireLocator
+	metadataCache *metadataCache
+	rateLimiter   *RateLimiter
+	secret        *secretHolder
+}
+
+// wireClient sets the shared state of the environment on a new client
+func (w *clientWiring) wireClient(client *Client) {
+	client.connectionEvents = w.connectionEvents
+	client.logger = w.logger
+	client.metrics = w.metrics
+	client.rateLimiter = w.rateLimiter
+	client.secret = w.secret
+}
+
+// wireLocator is like wireClient, and the locator also reads and fills the metadata cache
+func (w *clientWiring) wireLocator(client *Client) {
+	w.wireClient(client)
+	client.metadataCache = w.metadataCache
 }
 
 func NewEnvironment(options *EnvironmentOptions) (*Environment, error) {
+	return NewEnvironmentCtx(context.Background(), options)
+}
+
+// NewEnvironmentCtx is like NewEnvironment but the first connection
+// to the broker(s) is bound to the context.
+func NewEnvironmentCtx(ctx context.Context, options *EnvironmentOptions) (*Environment, error) {
 	if options == nil {
 		options = NewEnvironmentOptions()
 	}
 
+	if err := options.applyUriParameters(); err != nil {
+		return nil, err
+	}
+
 	if options.RPCTimeout <= 0 {
 		options.RPCTimeout = defaultSocketCallTimeout
 	}
+	if options.MaxPlacementAttempts <= 0 {
+		options.MaxPlacementAttempts = defaultMaxPlacementAttempts
+	}
+	if options.MetadataCacheTTL == 0 {
+		options.MetadataCacheTTL = defaultMetadataCacheTTL
+	}
+	if options.BackoffPolicy == nil {
+		options.BackoffPolicy = defaultBackoffPolicy()
+	}
+	if options.Logger == nil {
+		options.Logger = logs.NewStandardLogger()
+	}
+	if options.MetricsCollector == nil {
+		options.MetricsCollector = NoOpMetricsCollector{}
+	}
 
-	client := newClient("go-stream-locator", nil,
-		options.TCPParameters, options.SaslConfiguration, options.RPCTimeout)
-	defer func(client *Client) {
+	wiring := clientWiring{
+		connectionEvents: newConnectionEventsNotifier(options.Logger),
+		logger:           options.Logger,
+		metrics:          options.MetricsCollector,
+		rateLimiter:      options.RateLimiter,
+		secret:           newSecretHolder(),
+	}
+	// a new client for each broker, a failed connection can't be reused
+	newInitialClient := func() *Client {
+		client := newClient(options.locatorConnectionName(), nil,
+			options.TCPParameters, options.SaslConfiguration, options.RPCTimeout)
+		wiring.wireClient(client)
+		return client
+	}
+	var client *Client
+	defer func() {
+		if client == nil {
+			return
+		}
 		err := client.Close()
 		if err != nil {
 			return
 		}
-	}(client)
+	}()
 
 	if options.MaxConsumersPerClient <= 0 || options.MaxProducersPerClient <= 0 ||
 		options.MaxConsumersPerClient > 254 || options.MaxProducersPerClient > 254 {
 		return nil, fmt.Errorf(" MaxConsumersPerClient and MaxProducersPerClient must be between 1 and 254")
 	}
 
+	if options.LocatorPoolSize < 0 {
+		return nil, fmt.Errorf("LocatorPoolSize must be equal or bigger than 0")
+	}
+
 	if options.SaslConfiguration != nil {
-		if options.SaslConfiguration.Mechanism != SaslConfigurationPlain && options.SaslConfiguration.Mechanism != SaslConfigurationExternal {
-			return nil, fmt.Errorf("SaslConfiguration mechanism must be PLAIN or EXTERNAL")
+		if _, err := options.SaslConfiguration.saslMechanism(); err != nil {
+			return nil, err
 		}
 	}
 
 	if len(options.ConnectionParameters) == 0 {
 		options.ConnectionParameters = []*Broker{newBrokerDefault()}
 	}
+
+	var refreshIn time.Duration
+	if options.CredentialsProvider != nil {
+		current, next, err := options.CredentialsProvider()
+		if err != nil {
+			return nil, fmt.Errorf("can't get the credentials from the CredentialsProvider: %w", err)
+		}
+		wiring.secret.set(current)
+		refreshIn = next
+	}
+
 	var connectionError error
 	for idx, parameter := range options.ConnectionParameters {
 
@@ -77,147 +165,330 @@ func NewEnvironment(options *EnvironmentOptions) (*Environment, error) {
 
 		parameter.mergeWithDefault()
 
+		if client != nil {
+			_ = client.Close()
+		}
+		client = newInitialClient()
 		client.broker = parameter
 
-		connectionError = client.connect()
+		connectionError = client.connectCtx(ctx)
 		if connectionError == nil {
 			break
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
 		} else {
-			nextIfThereIs := ""
-			if idx < len(options.ConnectionParameters)-1 {
-				nextIfThereIs = "Trying the next broker..."
-			}
-			logs.LogError("New environment creation. Can't connect to the broker: %s port: %s. %s",
-				parameter.Host, parameter.Port, nextIfThereIs)
+			options.Logger.Error("New environment creation. Can't connect to the broker",
+				"host", parameter.Host, "port", parameter.Port, "error", connectionError,
+				"trying_next_broker", idx < len(options.ConnectionParameters)-1)
 
 		}
 	}
 
-	return &Environment{
+	env := &Environment{
 		options:   options,
 		producers: newProducers(options.MaxProducersPerClient),
 		consumers: newConsumerEnvironment(options.MaxConsumersPerClient),
 		closed:    false,
-	}, connectionError
+
+		clientWiring: wiring,
+	}
+	if options.MetadataCacheTTL > 0 {
+		env.metadataCache = newMetadataCache(options.MetadataCacheTTL)
+	}
+	if connectionError == nil && options.LocatorPoolSize > 0 {
+		env.locators = newLocatorPool(options, env.clientWiring)
+		env.locators.start(ctx)
+	}
+	if connectionError == nil && options.CredentialsProvider != nil {
+		refreshCtx, cancel := context.WithCancel(context.Background())
+		env.stopCredentialsRefresh = cancel
+		go env.refreshCredentials(refreshCtx, refreshIn)
+	}
+	return env, connectionError
 }
-func (env *Environment) newReconnectClient() (*Client, error) {
-	broker := env.options.ConnectionParameters[0]
-	client := newClient("go-stream-locator", broker, env.options.TCPParameters,
-		env.options.SaslConfiguration, env.options.RPCTimeout)
-
-	err := client.connect()
-	tentatives := 1
-	for err != nil {
-		sleepTime := rand.Intn(5000) + (tentatives * 1000)
-
-		brokerUri := fmt.Sprintf("%s://%s:***@%s:%s/%s", client.broker.Scheme, client.broker.User, client.broker.Host, client.broker.Port, client.broker.Vhost)
-		logs.LogError("Can't connect the locator client, error:%s, retry in %d milliseconds, broker: ", err, sleepTime, brokerUri)
-
-		time.Sleep(time.Duration(sleepTime) * time.Millisecond)
-		rand.Seed(time.Now().UnixNano())
-		n := rand.Intn(len(env.options.ConnectionParameters))
-		client = newClient("stream-locator", env.options.ConnectionParameters[n], env.options.TCPParameters,
-			env.options.SaslConfiguration, env.options.RPCTimeout)
-		tentatives = tentatives + 1
-		err = client.connect()
 
+// locatorClient returns a client for the management calls and the function to release it.
+// With the locator pool the client is shared and the release does nothing.
+// Without the pool (or when no pooled locator is connected) it is a new connection
+// closed by the release function.
+func (env *Environment) locatorClient(ctx context.Context) (*Client, func(), error) {
+	if env.locators != nil {
+		if client := env.locators.get(); client != nil {
+			return client, func() {}, nil
+		}
+		env.options.Logger.Debug("No locator connected in the pool, using a new connection")
 	}
+	client, err := env.newReconnectClientCtx(ctx)
+	return client, func() {
+		if client != nil {
+			_ = client.Close()
+		}
+	}, err
+}
 
-	return client, client.connect()
+func (env *Environment) newReconnectClient() (*Client, error) {
+	return env.newReconnectClientCtx(context.Background())
+}
+
+// newReconnectClientCtx connects a locator client.
+// It retries following the BackoffPolicy until the connection succeeds,
+// the attempts are exhausted or the context is done.
+// With a context deadline it doesn't wait for an attempt that would start after the deadline.
+func (env *Environment) newReconnectClientCtx(ctx context.Context) (*Client, error) {
+	var client *Client
+	var err error
+	for attempt := 1; ; attempt++ {
+		sleepTime, retry := env.GetBackoffPolicy().NextDelay(attempt)
+		if !retry {
+			return client, fmt.Errorf("%w: can't connect the locator client after %d attempts, last error: %v",
+				ErrRetriesExhausted, attempt-1, err)
+		}
+
+		// the first attempt goes to the first broker without waiting,
+		// the next ones wait the delay and pick a random broker
+		broker := env.options.ConnectionParameters[0]
+		if attempt > 1 {
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(sleepTime).After(deadline) {
+				return client, fmt.Errorf("can't connect the locator client before the deadline, last error: %w", err)
+			}
+
+			brokerUri := fmt.Sprintf("%s://%s:***@%s:%s/%s", client.broker.Scheme, client.broker.User, client.broker.Host, client.broker.Port, client.broker.Vhost)
+			env.options.Logger.Error("Can't connect the locator client", "broker", brokerUri,
+				"retry_in", sleepTime, "attempt", attempt-1, "error", err)
+
+			select {
+			case <-ctx.Done():
+				return client, ctx.Err()
+			case <-time.After(sleepTime):
+			}
+			_ = client.Close()
+			rand.Seed(time.Now().UnixNano())
+			broker = env.options.ConnectionParameters[rand.Intn(len(env.options.ConnectionParameters))]
+		}
+
+		client = newClient(env.options.locatorConnectionName(), broker, env.options.TCPParameters,
+			env.options.SaslConfiguration, env.options.RPCTimeout)
+		env.wireLocator(client)
+		err = client.connectCtx(ctx)
+		if err == nil {
+			return client, client.connectCtx(ctx)
+		}
+		if ctx.Err() != nil {
+			return client, ctx.Err()
+		}
+	}
 }
 
 func (env *Environment) DeclareStream(streamName string, options *StreamOptions) error {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.DeclareStreamCtx(context.Background(), streamName, options)
+}
+
+// DeclareStreamCtx is like DeclareStream but it returns ctx.Err() when the context is done
+func (env *Environment) DeclareStreamCtx(ctx context.Context, streamName string, options *StreamOptions) error {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return err
 	}
-	if err := client.DeclareStream(streamName, options); err != nil && err != StreamAlreadyExists {
+	if err := client.DeclareStreamCtx(ctx, streamName, options); err != nil && err != StreamAlreadyExists {
 		return err
 	}
 	return nil
 }
 
 func (env *Environment) DeleteStream(streamName string) error {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.DeleteStreamCtx(context.Background(), streamName)
+}
+
+// DeleteStreamCtx is like DeleteStream but it returns ctx.Err() when the context is done
+func (env *Environment) DeleteStreamCtx(ctx context.Context, streamName string) error {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return err
 	}
-	return client.DeleteStream(streamName)
+	env.metadataCache.invalidate(streamName)
+	return client.DeleteStreamCtx(ctx, streamName)
 }
 
 func (env *Environment) NewProducer(streamName string, producerOptions *ProducerOptions) (*Producer, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.NewProducerCtx(context.Background(), streamName, producerOptions)
+}
+
+// NewProducerCtx is like NewProducer but it returns ctx.Err() when the context is done
+// before the producer is declared
+func (env *Environment) NewProducerCtx(ctx context.Context, streamName string, producerOptions *ProducerOptions) (*Producer, error) {
+	if env.isShuttingDown() {
+		return nil, ErrShuttingDown
+	}
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return nil, err
 	}
 
-	return env.producers.newProducer(client, streamName, producerOptions, env.options.AddressResolver, env.options.RPCTimeout)
+	producer, err := env.producers.newProducer(ctx, client, streamName, producerOptions, env.options.AddressResolver,
+		env.options.MaxPlacementAttempts, env.options.RPCTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// the replay can block, it runs without the locks of the producers
+	if err := producer.replayOutbox(); err != nil {
+		_ = producer.Close()
+		return nil, err
+	}
+	return producer, nil
 }
 
 func (env *Environment) StreamExists(streamName string) (bool, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.StreamExistsCtx(context.Background(), streamName)
+}
+
+// StreamExistsCtx is like StreamExists but it returns ctx.Err() when the context is done
+func (env *Environment) StreamExistsCtx(ctx context.Context, streamName string) (bool, error) {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return false, err
 	}
-	return client.StreamExists(streamName), nil
+	return client.StreamExistsCtx(ctx, streamName)
 }
 
 func (env *Environment) QueryOffset(consumerName string, streamName string) (int64, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.QueryOffsetCtx(context.Background(), consumerName, streamName)
+}
+
+// QueryOffsetCtx is like QueryOffset but it returns ctx.Err() when the context is done
+func (env *Environment) QueryOffsetCtx(ctx context.Context, consumerName string, streamName string) (int64, error) {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return 0, err
 	}
-	return client.queryOffset(consumerName, streamName)
+	return client.queryOffsetCtx(ctx, consumerName, streamName)
 }
 
 // QuerySequence gets the last id stored for a producer
 // you can also see producer.GetLastPublishingId() that is the easier way to get the last-id
 func (env *Environment) QuerySequence(publisherReference string, streamName string) (int64, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.QuerySequenceCtx(context.Background(), publisherReference, streamName)
+}
+
+// QuerySequenceCtx is like QuerySequence but it returns ctx.Err() when the context is done
+func (env *Environment) QuerySequenceCtx(ctx context.Context, publisherReference string, streamName string) (int64, error) {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return 0, err
 	}
-	return client.queryPublisherSequence(publisherReference, streamName)
+	return client.queryPublisherSequenceCtx(ctx, publisherReference, streamName)
 }
 
 func (env *Environment) StreamStats(streamName string) (*StreamStats, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.StreamStatsCtx(context.Background(), streamName)
+}
+
+// StreamStatsCtx is like StreamStats but it returns ctx.Err() when the context is done
+func (env *Environment) StreamStatsCtx(ctx context.Context, streamName string) (*StreamStats, error) {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return nil, err
 	}
-	return client.StreamStats(streamName)
+	return client.StreamStatsCtx(ctx, streamName)
+}
+
+// StreamStatsResult is the result of a stream in StreamStatsMany, Err is set when the stats can't be read
+type StreamStatsResult struct {
+	Stream string
+	Stats  *StreamStats
+	Err    error
+}
+
+// StreamStatsMany reads the stats of the streams, the results are in the same order.
+// The requests are spread over the locator connections (see SetLocatorPoolSize), one request at a time for each connection,
+// the error is returned only when no locator can be connected
+func (env *Environment) StreamStatsMany(streams ...string) ([]StreamStatsResult, error) {
+	return env.StreamStatsManyCtx(context.Background(), streams...)
+}
+
+// StreamStatsManyCtx is like StreamStatsMany but it returns ctx.Err() when the context is done
+func (env *Environment) StreamStatsManyCtx(ctx context.Context, streams ...string) ([]StreamStatsResult, error) {
+	connections := env.options.LocatorPoolSize
+	if connections < 1 {
+		connections = 1
+	}
+	if connections > len(streams) {
+		connections = len(streams)
+	}
+	indexes := make(chan int, len(streams))
+	for i := range streams {
+		indexes <- i
+	}
+	close(indexes)
+
+	// one worker for each connection, the worker sends the requests one after the other
+	results := make([]StreamStatsResult, len(streams))
+	wg := sync.WaitGroup{}
+	for i := 0; i < connections; i++ {
+		client, release, err := env.locatorClient(ctx)
+		if err != nil {
+			release()
+			if i == 0 {
+				return nil, err
+			}
+			break
+		}
+		wg.Add(1)
+		go func(client *Client, release func()) {
+			defer wg.Done()
+			defer release()
+			for i := range indexes {
+				stats, err := client.StreamStatsCtx(ctx, streams[i])
+				results[i] = StreamStatsResult{Stream: streams[i], Stats: stats, Err: err}
+			}
+		}(client, release)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return results, nil
 }
 
 func (env *Environment) StreamMetaData(streamName string) (*StreamMetadata, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.StreamMetaDataCtx(context.Background(), streamName)
+}
+
+// StreamMetaDataCtx is like StreamMetaData but it returns ctx.Err() when the context is done
+// also during the wait for the leader election.
+// It always queries the broker, see StreamMetaDataMaxAge to use the cache
+func (env *Environment) StreamMetaDataCtx(ctx context.Context, streamName string) (*StreamMetadata, error) {
+	return env.StreamMetaDataMaxAgeCtx(ctx, streamName, 0)
+}
+
+// StreamMetaDataMaxAge returns the cached metadata if it is younger than maxAge,
+// otherwise it queries the broker and refreshes the cache. maxAge 0 always queries the broker,
+// as StreamMetaData does
+func (env *Environment) StreamMetaDataMaxAge(streamName string, maxAge time.Duration) (*StreamMetadata, error) {
+	return env.StreamMetaDataMaxAgeCtx(context.Background(), streamName, maxAge)
+}
+
+// StreamMetaDataMaxAgeCtx is like StreamMetaDataMaxAge but it returns ctx.Err() when the context is done
+func (env *Environment) StreamMetaDataMaxAgeCtx(ctx context.Context, streamName string, maxAge time.Duration) (*StreamMetadata, error) {
+	if metadata := env.metadataCache.get(streamName, maxAge); metadata != nil {
+		return metadata, nil
+	}
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return nil, err
 	}
-	streamsMetadata := client.metaData(streamName)
+	env.metadataCache.countRequest()
+	streamsMetadata := client.metaDataCtx(ctx, streamName)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	streamMetadata := streamsMetadata.Get(streamName)
 	if streamMetadata.responseCode != responseCodeOk {
 		return nil, lookErrorCode(streamMetadata.responseCode)
@@ -225,10 +496,18 @@ func (env *Environment) StreamMetaData(streamName string) (*StreamMetadata, erro
 
 	tentatives := 0
 	for streamMetadata == nil || streamMetadata.Leader == nil && tentatives < 3 {
-		streamsMetadata = client.metaData(streamName)
+		env.metadataCache.countRequest()
+		streamsMetadata = client.metaDataCtx(ctx, streamName)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		streamMetadata = streamsMetadata.Get(streamName)
 		tentatives++
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
 	}
 
 	if streamMetadata.Leader == nil {
@@ -236,34 +515,60 @@ func (env *Environment) StreamMetaData(streamName string) (*StreamMetadata, erro
 		return nil, LeaderNotReady
 	}
 
+	env.metadataCache.put(streamMetadata)
 	return streamMetadata, nil
 }
 
 func (env *Environment) NewConsumer(streamName string,
 	messagesHandler MessagesHandler,
 	options *ConsumerOptions) (*Consumer, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.NewConsumerCtx(context.Background(), streamName, messagesHandler, options)
+}
+
+// NewConsumerCtx is like NewConsumer but it returns ctx.Err() when the context is done
+// before the consumer is subscribed
+func (env *Environment) NewConsumerCtx(ctx context.Context, streamName string,
+	messagesHandler MessagesHandler,
+	options *ConsumerOptions) (*Consumer, error) {
+	if env.isShuttingDown() {
+		return nil, ErrShuttingDown
+	}
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return nil, err
 	}
 
-	return env.consumers.NewSubscriber(client, streamName, messagesHandler, options, env.options.AddressResolver, env.options.RPCTimeout)
+	return env.consumers.NewSubscriber(ctx, client, streamName, messagesHandler, options, env.options.AddressResolver,
+		env.options.MaxPlacementAttempts, env.options.RPCTimeout)
 }
 
 func (env *Environment) NewSuperStreamProducer(superStream string, superStreamProducerOptions *SuperStreamProducerOptions) (*SuperStreamProducer, error) {
+	return env.NewSuperStreamProducerCtx(context.Background(), superStream, superStreamProducerOptions)
+}
+
+// NewSuperStreamProducerCtx is like NewSuperStreamProducer but the partitions query
+// and the producers creation are bound to the context
+func (env *Environment) NewSuperStreamProducerCtx(ctx context.Context, superStream string, superStreamProducerOptions *SuperStreamProducerOptions) (*SuperStreamProducer, error) {
 	var p, err = newSuperStreamProducer(env, superStream, superStreamProducerOptions)
 	if err != nil {
 		return nil, err
 	}
-	return p, p.init()
+	return p, p.initCtx(ctx)
 }
 
 func (env *Environment) Close() error {
+	if env.stopCredentialsRefresh != nil {
+		env.stopCredentialsRefresh()
+	}
 	_ = env.producers.close()
 	_ = env.consumers.close()
+	if env.locators != nil {
+		env.locators.close()
+	}
+	if env.connectionEvents != nil {
+		env.connectionEvents.close()
+	}
 	env.closed = true
 	return nil
 }
@@ -272,6 +577,30 @@ func (env *Environment) IsClosed() bool {
 	return env.closed
 }
 
+// GetBackoffPolicy returns the BackoffPolicy of the environment.
+// It is used by the ha ReliableProducer and ReliableConsumer when they don't define their own policy
+func (env *Environment) GetBackoffPolicy() BackoffPolicy {
+	return env.options.BackoffPolicy
+}
+
+// GetMetricsCollector returns the MetricsCollector of the environment, see EnvironmentOptions.SetMetricsCollector
+func (env *Environment) GetMetricsCollector() MetricsCollector {
+	return env.options.MetricsCollector
+}
+
+// GetLogger returns the Logger of the environment, see EnvironmentOptions.SetLogger
+func (env *Environment) GetLogger() logs.Logger {
+	return env.options.Logger
+}
+
+// NotifyConnectionEvents returns a channel with the lifecycle events of all the connections
+// of the environment: locators, producers and consumers.
+// The channel is buffered and the events are dropped when it is full, so the channel
+// should be consumed. It is closed by env.Close()
+func (env *Environment) NotifyConnectionEvents() ChannelConnectionEvent {
+	return env.connectionEvents.register()
+}
+
 type EnvironmentOptions struct {
 	ConnectionParameters  []*Broker
 	TCPParameters         *TCPParameters
@@ -279,7 +608,17 @@ type EnvironmentOptions struct {
 	MaxProducersPerClient int
 	MaxConsumersPerClient int
 	AddressResolver       *AddressResolver
+	MaxPlacementAttempts  int // Connection attempts to reach the expected node through the AddressResolver
 	RPCTimeout            time.Duration
+	LocatorPoolSize       int // Number of long-lived locator connections for the management calls. 0 (default) opens a connection per call
+	CredentialsProvider   CredentialsProvider
+	BackoffPolicy         BackoffPolicy
+	Logger                logs.Logger
+	MetricsCollector      MetricsCollector
+	ConnectionName        string // name of the locator connections, default go-stream-locator
+	StreamConfigReader    StreamConfigReader
+	MetadataCacheTTL      time.Duration // How long the leader and the replicas of a stream are cached, default 30s. Negative disables the cache
+	RateLimiter           *RateLimiter  // shared by all the producers of the environment, nil is no limit
 }
 
 func NewEnvironmentOptions() *EnvironmentOptions {
@@ -290,6 +629,11 @@ func NewEnvironmentOptions() *EnvironmentOptions {
 		TCPParameters:         newTCPParameterDefault(),
 		SaslConfiguration:     newSaslConfigurationDefault(),
 		RPCTimeout:            defaultSocketCallTimeout,
+		MaxPlacementAttempts:  defaultMaxPlacementAttempts,
+		MetadataCacheTTL:      defaultMetadataCacheTTL,
+		BackoffPolicy:         defaultBackoffPolicy(),
+		Logger:                logs.NewStandardLogger(),
+		MetricsCollector:      NoOpMetricsCollector{},
 	}
 }
 
@@ -301,6 +645,27 @@ func (envOptions *EnvironmentOptions) SetAddressResolver(addressResolver Address
 	return envOptions
 }
 
+// SetMaxPlacementAttempts sets how many times the client re-dials the AddressResolver
+// to reach the leader (producers) or a replica (consumers). The default is 20
+func (envOptions *EnvironmentOptions) SetMaxPlacementAttempts(attempts int) *EnvironmentOptions {
+	envOptions.MaxPlacementAttempts = attempts
+	return envOptions
+}
+
+// SetRateLimiter limits the publish rate of all the producers together,
+// a producer with its own ProducerOptions.RateLimiter waits for both
+func (envOptions *EnvironmentOptions) SetRateLimiter(limiter *RateLimiter) *EnvironmentOptions {
+	envOptions.RateLimiter = limiter
+	return envOptions
+}
+
+// SetMetadataCacheTTL sets how long the leader and the replicas of a stream are cached to create the producers
+// and the consumers. The MetadataUpdate notifications remove the entries before. A negative value disables the cache
+func (envOptions *EnvironmentOptions) SetMetadataCacheTTL(ttl time.Duration) *EnvironmentOptions {
+	envOptions.MetadataCacheTTL = ttl
+	return envOptions
+}
+
 func (envOptions *EnvironmentOptions) SetMaxProducersPerClient(maxProducersPerClient int) *EnvironmentOptions {
 	envOptions.MaxProducersPerClient = maxProducersPerClient
 	return envOptions
@@ -452,17 +817,98 @@ func (envOptions *EnvironmentOptions) SetNoDelay(noDelay bool) *EnvironmentOptio
 	return envOptions
 }
 
+// SetDialer sets the function used to open the connections, see Dialer and NewSocks5Dialer
+func (envOptions *EnvironmentOptions) SetDialer(dialer Dialer) *EnvironmentOptions {
+	if envOptions.TCPParameters == nil {
+		envOptions.TCPParameters = newTCPParameterDefault()
+	}
+	envOptions.TCPParameters.Dialer = dialer
+
+	return envOptions
+}
+
 func (envOptions *EnvironmentOptions) SetRPCTimeout(timeout time.Duration) *EnvironmentOptions {
 	envOptions.RPCTimeout = timeout
 	return envOptions
 }
 
+// SetSaslMechanism sets a SaslMechanism implementation, for example NewScramSha256SaslMechanism(),
+// NewOAuth2SaslMechanism(provider) or a custom one. It takes precedence over SetSaslConfiguration
+func (envOptions *EnvironmentOptions) SetSaslMechanism(mechanism SaslMechanism) *EnvironmentOptions {
+	if envOptions.SaslConfiguration == nil {
+		envOptions.SaslConfiguration = newSaslConfigurationDefault()
+	}
+	envOptions.SaslConfiguration.SaslMechanism = mechanism
+	return envOptions
+}
+
+// SetCredentialsProvider sets the provider used to rotate the secret of the connections.
+// The provider is called when the environment is created and then after the returned refreshIn
+// to push the new secret to all the connections, see CredentialsProvider
+func (envOptions *EnvironmentOptions) SetCredentialsProvider(provider CredentialsProvider) *EnvironmentOptions {
+	envOptions.CredentialsProvider = provider
+	return envOptions
+}
+
+// SetBackoffPolicy sets the delay between the reconnection attempts, see BackoffPolicy.
+// The default is an exponential backoff with jitter that never stops retrying
+func (envOptions *EnvironmentOptions) SetBackoffPolicy(policy BackoffPolicy) *EnvironmentOptions {
+	envOptions.BackoffPolicy = policy
+	return envOptions
+}
+
+// SetLogger sets the Logger used by the environment, the connections, the producers and the consumers.
+// The default logger writes to the standard log package filtered by logs.LogLevel,
+// use logs.NewSlogLogger to route the logs to a slog.Logger
+func (envOptions *EnvironmentOptions) SetLogger(logger logs.Logger) *EnvironmentOptions {
+	envOptions.Logger = logger
+	return envOptions
+}
+
+// SetMetricsCollector sets the MetricsCollector called by the producers, the consumers and the connections.
+// The default collector does nothing, see the metrics package for an in-memory collector and the expvar exporter
+func (envOptions *EnvironmentOptions) SetMetricsCollector(collector MetricsCollector) *EnvironmentOptions {
+	envOptions.MetricsCollector = collector
+	return envOptions
+}
+
+// SetConnectionName sets the name of the locator connections, visible in the management UI
+func (envOptions *EnvironmentOptions) SetConnectionName(name string) *EnvironmentOptions {
+	envOptions.ConnectionName = name
+	return envOptions
+}
+
+func (envOptions *EnvironmentOptions) locatorConnectionName() string {
+	if envOptions.ConnectionName == "" {
+		return defaultLocatorConnectionName
+	}
+	return envOptions.ConnectionName
+}
+
+// SetStreamConfigReader sets the reader used by EnsureStream to read back the arguments of an existing stream
+func (envOptions *EnvironmentOptions) SetStreamConfigReader(reader StreamConfigReader) *EnvironmentOptions {
+	envOptions.StreamConfigReader = reader
+	return envOptions
+}
+
+// SetLocatorPoolSize enables the pool of long-lived locator connections.
+// The management calls like DeclareStream, StreamStats, QueryOffset, QueryRoute... reuse these connections
+// instead of opening a new one for each call. The connections are spread across the ConnectionParameters
+// and re-dialed in background when broken.
+func (envOptions *EnvironmentOptions) SetLocatorPoolSize(size int) *EnvironmentOptions {
+	envOptions.LocatorPoolSize = size
+	return envOptions
+}
+
 type environmentCoordinator struct {
 	mutex             *sync.Mutex
 	mutexContext      *sync.RWMutex
 	clientsPerContext map[int]*Client
 	maxItemsForClient int
 	nextId            int
+	// maxPlacementAttempts limits the re-dials through the load balancer, see placeClient
+	maxPlacementAttempts int
+	clientWiring
 }
 
 func (cc *environmentCoordinator) isProducerListFull(clientsPerContextId int) bool {
@@ -483,6 +929,7 @@ func (cc *environmentCoordinator) maybeCleanClients() {
 	for i, client := range cc.clientsPerContext {
 		if !client.socket.isOpen() {
 			delete(cc.clientsPerContext, i)
+			client.notifyConnectionEvent(ConnectionEventReaped, "connection closed", nil)
 		}
 	}
 }
@@ -537,15 +984,17 @@ func (c *Client) maybeCleanConsumers(streamName string) {
 	}
 }
 
-func (cc *environmentCoordinator) newProducer(leader *Broker, tcpParameters *TCPParameters, saslConfiguration *SaslConfiguration, streamName string,
+func (cc *environmentCoordinator) newProducer(ctx context.Context, leader *Broker, tcpParameters *TCPParameters, saslConfiguration *SaslConfiguration, streamName string,
 	options *ProducerOptions, rpcTimeout time.Duration) (*Producer, error) {
 	cc.mutex.Lock()
 	defer cc.mutex.Unlock()
 	cc.mutexContext.Lock()
 	defer cc.mutexContext.Unlock()
+	// the producer must be connected to the leader
+	expected := []*Broker{leader}
 	var clientResult *Client
 	for i, client := range cc.clientsPerContext {
-		if !cc.isProducerListFull(i) {
+		if !cc.isProducerListFull(i) && client.isPlacementCandidate(expected) {
 			clientResult = client
 			break
 		}
@@ -559,29 +1008,14 @@ func (cc *environmentCoordinator) newProducer(leader *Broker, tcpParameters *TCP
 		clientResult = cc.newClientForProducer(clientProvidedName, leader, tcpParameters, saslConfiguration, rpcTimeout)
 	}
 
-	err := clientResult.connect()
+	clientResult, err := cc.placeClient(ctx, clientResult, streamName, expected, func() *Client {
+		return cc.newClientForProducer(clientProvidedName, leader, tcpParameters, saslConfiguration, rpcTimeout)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	for clientResult.connectionProperties.host != leader.advHost ||
-		clientResult.connectionProperties.port != leader.advPort {
-		logs.LogDebug("connectionProperties host %s doesn't match with the advertised_host %s, advertised_port %s .. retry",
-			clientResult.connectionProperties.host,
-			leader.advHost, leader.advPort)
-		err := clientResult.Close()
-		if err != nil {
-			return nil, err
-		}
-		clientResult = cc.newClientForProducer(clientProvidedName, leader, tcpParameters, saslConfiguration, rpcTimeout)
-		err = clientResult.connect()
-		if err != nil {
-			return nil, err
-		}
-		time.Sleep(1 * time.Second)
-	}
-
-	producer, err := clientResult.DeclarePublisher(streamName, options)
+	producer, err := clientResult.declarePublisherCtx(ctx, streamName, options)
 
 	if err != nil {
 		return nil, err
@@ -592,10 +1026,13 @@ func (cc *environmentCoordinator) newProducer(leader *Broker, tcpParameters *TCP
 
 func (cc *environmentCoordinator) newClientForProducer(connectionName string, leader *Broker, tcpParameters *TCPParameters, saslConfiguration *SaslConfiguration, rpcTimeOut time.Duration) *Client {
 	clientResult := newClient(connectionName, leader, tcpParameters, saslConfiguration, rpcTimeOut)
+	cc.wireClient(clientResult)
+	clientResult.onClose = cc.metadataCache.invalidateStreams
 	chMeta := make(chan metaDataUpdateEvent, 1)
 	clientResult.metadataListener = chMeta
 	go func(ch <-chan metaDataUpdateEvent, cl *Client) {
 		for metaDataUpdateEvent := range ch {
+			cc.metadataCache.invalidate(metaDataUpdateEvent.StreamName)
 			clientResult.maybeCleanProducers(metaDataUpdateEvent.StreamName)
 			cc.maybeCleanClients()
 			if !cl.socket.isOpen() {
@@ -610,7 +1047,10 @@ func (cc *environmentCoordinator) newClientForProducer(connectionName string, le
 	return clientResult
 }
 
-func (cc *environmentCoordinator) newConsumer(connectionName string, leader *Broker, tcpParameters *TCPParameters, saslConfiguration *SaslConfiguration,
+// newConsumer places the consumer on a client connected to one of the expected nodes.
+// expected is empty when the connection goes directly to the broker (no AddressResolver)
+func (cc *environmentCoordinator) newConsumer(ctx context.Context, connectionName string, broker *Broker, expected []*Broker,
+	tcpParameters *TCPParameters, saslConfiguration *SaslConfiguration,
 	streamName string, messagesHandler MessagesHandler,
 	options *ConsumerOptions, rpcTimeout time.Duration) (*Consumer, error) {
 	cc.mutex.Lock()
@@ -619,37 +1059,24 @@ func (cc *environmentCoordinator) newConsumer(connectionName string, leader *Bro
 	defer cc.mutexContext.Unlock()
 	var clientResult *Client
 	for i, client := range cc.clientsPerContext {
-		if !cc.isConsumerListFull(i) {
+		if !cc.isConsumerListFull(i) && client.isPlacementCandidate(expected) {
 			clientResult = client
 			break
 		}
 	}
 
 	if clientResult == nil {
-		clientResult = newClient(connectionName, leader, tcpParameters, saslConfiguration, rpcTimeout)
-		chMeta := make(chan metaDataUpdateEvent)
-		clientResult.metadataListener = chMeta
-		go func(ch <-chan metaDataUpdateEvent, cl *Client) {
-			for metaDataUpdateEvent := range ch {
-				clientResult.maybeCleanConsumers(metaDataUpdateEvent.StreamName)
-				cc.maybeCleanClients()
-				if !cl.socket.isOpen() {
-					return
-				}
-			}
-
-		}(chMeta, clientResult)
-
-		cc.nextId++
-		cc.clientsPerContext[cc.nextId] = clientResult
+		clientResult = cc.newClientForConsumer(connectionName, broker, tcpParameters, saslConfiguration, rpcTimeout)
 	}
 	// try to reconnect in case the socket is closed
-	err := clientResult.connect()
+	clientResult, err := cc.placeClient(ctx, clientResult, streamName, expected, func() *Client {
+		return cc.newClientForConsumer(connectionName, broker, tcpParameters, saslConfiguration, rpcTimeout)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	subscriber, err := clientResult.DeclareSubscriber(streamName, messagesHandler, options)
+	subscriber, err := clientResult.DeclareSubscriberCtx(ctx, streamName, messagesHandler, options)
 
 	if err != nil {
 		return nil, err
@@ -657,13 +1084,36 @@ func (cc *environmentCoordinator) newConsumer(connectionName string, leader *Bro
 	return subscriber, nil
 }
 
+func (cc *environmentCoordinator) newClientForConsumer(connectionName string, broker *Broker, tcpParameters *TCPParameters, saslConfiguration *SaslConfiguration, rpcTimeOut time.Duration) *Client {
+	clientResult := newClient(connectionName, broker, tcpParameters, saslConfiguration, rpcTimeOut)
+	cc.wireClient(clientResult)
+	clientResult.onClose = cc.metadataCache.invalidateStreams
+	chMeta := make(chan metaDataUpdateEvent)
+	clientResult.metadataListener = chMeta
+	go func(ch <-chan metaDataUpdateEvent, cl *Client) {
+		for metaDataUpdateEvent := range ch {
+			cc.metadataCache.invalidate(metaDataUpdateEvent.StreamName)
+			clientResult.maybeCleanConsumers(metaDataUpdateEvent.StreamName)
+			cc.maybeCleanClients()
+			if !cl.socket.isOpen() {
+				return
+			}
+		}
+
+	}(chMeta, clientResult)
+
+	cc.nextId++
+	cc.clientsPerContext[cc.nextId] = clientResult
+	return clientResult
+}
+
 func (cc *environmentCoordinator) Close() error {
 	cc.mutexContext.Lock()
 	defer cc.mutexContext.Unlock()
 	for _, client := range cc.clientsPerContext {
 		err := client.Close()
 		if err != nil {
-			logs.LogWarn("Error during close the client, %s", err)
+			cc.logger.Warn("Error during close the client", "error", err)
 		}
 	}
 	return nil
@@ -675,6 +1125,16 @@ func (cc *environmentCoordinator) getClientsPerContext() map[int]*Client {
 	return cc.clientsPerContext
 }
 
+func (cc *environmentCoordinator) clients() []*Client {
+	cc.mutexContext.Lock()
+	defer cc.mutexContext.Unlock()
+	clients := make([]*Client, 0, len(cc.clientsPerContext))
+	for _, client := range cc.clientsPerContext {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
 type producersEnvironment struct {
 	mutex                *sync.Mutex
 	producersCoordinator map[string]*environmentCoordinator
@@ -690,11 +1150,11 @@ func newProducers(maxItemsForClient int) *producersEnvironment {
 	return producers
 }
 
-func (ps *producersEnvironment) newProducer(clientLocator *Client, streamName string,
-	options *ProducerOptions, resolver *AddressResolver, rpcTimeOut time.Duration) (*Producer, error) {
+func (ps *producersEnvironment) newProducer(ctx context.Context, clientLocator *Client, streamName string,
+	options *ProducerOptions, resolver *AddressResolver, maxPlacementAttempts int, rpcTimeOut time.Duration) (*Producer, error) {
 	ps.mutex.Lock()
 	defer ps.mutex.Unlock()
-	leader, err := clientLocator.BrokerLeader(streamName)
+	leader, err := clientLocator.BrokerLeaderCtx(ctx, streamName)
 	if err != nil {
 		return nil, err
 	}
@@ -706,13 +1166,17 @@ func (ps *producersEnvironment) newProducer(clientLocator *Client, streamName st
 			maxItemsForClient: ps.maxItemsForClient,
 			mutexContext:      &sync.RWMutex{},
 			nextId:            0,
+			clientWiring:      clientLocator.wiring(),
 		}
 	}
+	ps.producersCoordinator[coordinatorKey].maxPlacementAttempts = maxPlacementAttempts
 	leader.cloneFrom(clientLocator.broker, resolver)
 
-	producer, err := ps.producersCoordinator[coordinatorKey].newProducer(leader, clientLocator.tcpParameters,
+	producer, err := ps.producersCoordinator[coordinatorKey].newProducer(ctx, leader, clientLocator.tcpParameters,
 		clientLocator.saslConfiguration, streamName, options, rpcTimeOut)
 	if err != nil {
+		// the leader can be stale, ex: StreamNotAvailable
+		clientLocator.metadataCache.invalidate(streamName)
 		return nil, err
 	}
 	producer.onClose = func(ch <-chan uint8) {
@@ -736,7 +1200,12 @@ func (ps *producersEnvironment) close() error {
 func (ps *producersEnvironment) getCoordinators() map[string]*environmentCoordinator {
 	ps.mutex.Lock()
 	defer ps.mutex.Unlock()
-	return ps.producersCoordinator
+	// a copy, so the caller can iterate without the lock
+	coordinators := make(map[string]*environmentCoordinator, len(ps.producersCoordinator))
+	for key, coordinator := range ps.producersCoordinator {
+		coordinators[key] = coordinator
+	}
+	return coordinators
 }
 
 type consumersEnvironment struct {
@@ -754,12 +1223,23 @@ func newConsumerEnvironment(maxItemsForClient int) *consumersEnvironment {
 	return producers
 }
 
-func (ps *consumersEnvironment) NewSubscriber(clientLocator *Client, streamName string,
+func (ps *consumersEnvironment) NewSubscriber(ctx context.Context, clientLocator *Client, streamName string,
 	messagesHandler MessagesHandler,
-	consumerOptions *ConsumerOptions, resolver *AddressResolver, rpcTimeout time.Duration) (*Consumer, error) {
+	consumerOptions *ConsumerOptions, resolver *AddressResolver, maxPlacementAttempts int, rpcTimeout time.Duration) (*Consumer, error) {
 	ps.mutex.Lock()
 	defer ps.mutex.Unlock()
-	consumerBroker, err := clientLocator.BrokerForConsumer(streamName)
+	var consumerBroker *Broker
+	var expected []*Broker
+	var err error
+	if resolver == nil {
+		consumerBroker, err = clientLocator.BrokerForConsumerCtx(ctx, streamName)
+	} else {
+		// behind the load balancer the connection is checked against the replicas
+		expected, err = clientLocator.consumerCandidatesCtx(ctx, streamName)
+		if err == nil {
+			consumerBroker = expected[rand.Intn(len(expected))]
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -771,18 +1251,22 @@ func (ps *consumersEnvironment) NewSubscriber(clientLocator *Client, streamName
 			maxItemsForClient: ps.maxItemsForClient,
 			mutexContext:      &sync.RWMutex{},
 			nextId:            0,
+			clientWiring:      clientLocator.wiring(),
 		}
 	}
+	ps.consumersCoordinator[coordinatorKey].maxPlacementAttempts = maxPlacementAttempts
 	consumerBroker.cloneFrom(clientLocator.broker, resolver)
 	clientProvidedName := "go-stream-consumer"
 	if consumerOptions != nil && consumerOptions.ClientProvidedName != "" {
 		clientProvidedName = consumerOptions.ClientProvidedName
 	}
 	consumer, err := ps.consumersCoordinator[coordinatorKey].
-		newConsumer(clientProvidedName, consumerBroker, clientLocator.tcpParameters,
+		newConsumer(ctx, clientProvidedName, consumerBroker, expected, clientLocator.tcpParameters,
 			clientLocator.saslConfiguration,
 			streamName, messagesHandler, consumerOptions, rpcTimeout)
 	if err != nil {
+		// the replicas can be stale, ex: StreamNotAvailable
+		clientLocator.metadataCache.invalidate(streamName)
 		return nil, err
 	}
 	consumer.onClose = func(ch <-chan uint8) {
@@ -805,63 +1289,86 @@ func (ps *consumersEnvironment) close() error {
 func (ps *consumersEnvironment) getCoordinators() map[string]*environmentCoordinator {
 	ps.mutex.Lock()
 	defer ps.mutex.Unlock()
-	return ps.consumersCoordinator
+	// a copy, so the caller can iterate without the lock
+	coordinators := make(map[string]*environmentCoordinator, len(ps.consumersCoordinator))
+	for key, coordinator := range ps.consumersCoordinator {
+		coordinators[key] = coordinator
+	}
+	return coordinators
 }
 
 // Super stream
 
 func (env *Environment) DeclareSuperStream(superStreamName string, options SuperStreamOptions) error {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.DeclareSuperStreamCtx(context.Background(), superStreamName, options)
+}
+
+// DeclareSuperStreamCtx is like DeclareSuperStream but it returns ctx.Err() when the context is done
+func (env *Environment) DeclareSuperStreamCtx(ctx context.Context, superStreamName string, options SuperStreamOptions) error {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return err
 	}
-	if err := client.DeclareSuperStream(superStreamName, options); err != nil && !errors.Is(err, StreamAlreadyExists) {
+	if err := client.DeclareSuperStreamCtx(ctx, superStreamName, options); err != nil && !errors.Is(err, StreamAlreadyExists) {
 		return err
 	}
 	return nil
 }
 
 func (env *Environment) DeleteSuperStream(superStreamName string) error {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.DeleteSuperStreamCtx(context.Background(), superStreamName)
+}
+
+// DeleteSuperStreamCtx is like DeleteSuperStream but it returns ctx.Err() when the context is done
+func (env *Environment) DeleteSuperStreamCtx(ctx context.Context, superStreamName string) error {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return err
 	}
-	return client.DeleteSuperStream(superStreamName)
+	return client.DeleteSuperStreamCtx(ctx, superStreamName)
 }
 
 func (env *Environment) QueryPartitions(superStreamName string) ([]string, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.QueryPartitionsCtx(context.Background(), superStreamName)
+}
+
+// QueryPartitionsCtx is like QueryPartitions but it returns ctx.Err() when the context is done
+func (env *Environment) QueryPartitionsCtx(ctx context.Context, superStreamName string) ([]string, error) {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return nil, err
 	}
-	return client.QueryPartitions(superStreamName)
+	return client.QueryPartitionsCtx(ctx, superStreamName)
 }
 
 func (env *Environment) QueryRoute(superStream string, routingKey string) ([]string, error) {
-	client, err := env.newReconnectClient()
-	defer func(client *Client) {
-		_ = client.Close()
-	}(client)
+	return env.QueryRouteCtx(context.Background(), superStream, routingKey)
+}
+
+// QueryRouteCtx is like QueryRoute but it returns ctx.Err() when the context is done
+func (env *Environment) QueryRouteCtx(ctx context.Context, superStream string, routingKey string) ([]string, error) {
+	client, release, err := env.locatorClient(ctx)
+	defer release()
 	if err != nil {
 		return nil, err
 	}
-	return client.queryRoute(superStream, routingKey)
+	return client.queryRouteCtx(ctx, superStream, routingKey)
 }
 
 func (env *Environment) NewSuperStreamConsumer(superStream string, messagesHandler MessagesHandler, options *SuperStreamConsumerOptions) (*SuperStreamConsumer, error) {
+	return env.NewSuperStreamConsumerCtx(context.Background(), superStream, messagesHandler, options)
+}
+
+// NewSuperStreamConsumerCtx is like NewSuperStreamConsumer but the partitions query
+// and the consumers creation are bound to the context
+func (env *Environment) NewSuperStreamConsumerCtx(ctx context.Context, superStream string, messagesHandler MessagesHandler, options *SuperStreamConsumerOptions) (*SuperStreamConsumer, error) {
 	s, err := newSuperStreamConsumer(env, superStream, messagesHandler, options)
 	if err != nil {
 		return nil, err
 	}
-	err = s.init()
+	err = s.initCtx(ctx)
 	return s, err
 }
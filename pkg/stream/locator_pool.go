@@ -0,0 +1,157 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// locatorPool keeps a set of long-lived locator connections.
+// The locators are used by the Environment for the management calls
+// (DeclareStream, StreamStats, QueryOffset, ...) to avoid a full
+// TCP+TLS+SASL+tune handshake for each call.
+// The locator index is used to pick the broker, so the connections are spread
+// across the ConnectionParameters.
+// A background task checks the connections and re-dials the broken ones.
+// The heartbeat is handled by the client itself, see Client.heartBeat
+type locatorPool struct {
+	mutex    *sync.Mutex
+	options  *EnvironmentOptions
+	locators []*Client
+	next     int
+	closed   bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	clientWiring
+}
+
+func newLocatorPool(options *EnvironmentOptions, wiring clientWiring) *locatorPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &locatorPool{
+		mutex:    &sync.Mutex{},
+		options:  options,
+		locators: make([]*Client, options.LocatorPoolSize),
+		ctx:      ctx,
+		cancel:   cancel,
+
+		clientWiring: wiring,
+	}
+}
+
+// start dials the locators and starts the health check task.
+// The locators that can't be connected now are re-dialed in background
+func (lp *locatorPool) start(ctx context.Context) {
+	lp.maintain(ctx)
+	go func() {
+		ticker := time.NewTicker(defaultLocatorHealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-lp.ctx.Done():
+				return
+			case <-ticker.C:
+				lp.maintain(lp.ctx)
+			}
+		}
+	}()
+}
+
+// maintain re-dials the locators that are not connected
+func (lp *locatorPool) maintain(ctx context.Context) {
+	for i := 0; i < lp.options.LocatorPoolSize; i++ {
+		lp.mutex.Lock()
+		if lp.closed {
+			lp.mutex.Unlock()
+			return
+		}
+		locator := lp.locators[i]
+		lp.mutex.Unlock()
+
+		if locator != nil && locator.socket.isOpen() {
+			continue
+		}
+
+		if locator != nil {
+			lp.options.Logger.Debug("Locator is not connected, re-dialing", "locator", i)
+		}
+		newLocator, err := lp.dial(ctx, i)
+		if locator != nil {
+			lp.options.MetricsCollector.Reconnection(lp.options.locatorConnectionName(), err)
+		}
+		if err != nil {
+			lp.options.Logger.Warn("Can't connect the locator, it will be retried",
+				"locator", i, "retry_in", defaultLocatorHealthCheckInterval, "error", err)
+		}
+
+		lp.mutex.Lock()
+		if lp.closed {
+			lp.mutex.Unlock()
+			if newLocator != nil {
+				_ = newLocator.Close()
+			}
+			return
+		}
+		lp.locators[i] = newLocator
+		lp.mutex.Unlock()
+	}
+}
+
+// dial connects a locator starting from the broker assigned to the index.
+// In case of failure it tries the other brokers.
+func (lp *locatorPool) dial(ctx context.Context, index int) (*Client, error) {
+	brokers := lp.options.ConnectionParameters
+	var err error
+	for attempt := 0; attempt < len(brokers); attempt++ {
+		broker := brokers[(index+attempt)%len(brokers)]
+		client := newClient(lp.options.locatorConnectionName(), broker, lp.options.TCPParameters,
+			lp.options.SaslConfiguration, lp.options.RPCTimeout)
+		lp.wireLocator(client)
+		err = client.connectCtx(ctx)
+		if err == nil {
+			return client, nil
+		}
+		_ = client.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+// get returns the next connected locator (round-robin).
+// It returns nil if there are no connected locators
+func (lp *locatorPool) get() *Client {
+	lp.mutex.Lock()
+	defer lp.mutex.Unlock()
+	if lp.closed {
+		return nil
+	}
+	for i := 0; i < len(lp.locators); i++ {
+		lp.next = (lp.next + 1) % len(lp.locators)
+		locator := lp.locators[lp.next]
+		if locator != nil && locator.socket.isOpen() {
+			return locator
+		}
+	}
+	return nil
+}
+
+func (lp *locatorPool) close() {
+	lp.mutex.Lock()
+	if lp.closed {
+		lp.mutex.Unlock()
+		return
+	}
+	lp.closed = true
+	lp.cancel()
+	locators := lp.locators
+	lp.locators = make([]*Client, len(locators))
+	lp.mutex.Unlock()
+
+	for _, locator := range locators {
+		if locator != nil {
+			_ = locator.Close()
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metadataCache keeps the leader and the replicas of the streams, so the creation of
+// producers and consumers doesn't query the locator each time.
+// The cache is used only to place the producers and consumers (see cachedMetaDataCtx),
+// Environment.StreamMetaData always queries the broker.
+// An entry is removed by the MetadataUpdate frames received by the producers and consumers connections,
+// when a producers or consumers connection is closed, by the failed creations (ex: StreamNotAvailable)
+// and after the ttl.
+// The methods accept a nil cache (disabled)
+type metadataCache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	streams map[string]cachedStreamMetadata
+	// requests counts the metadata requests sent to the broker by the cache users
+	requests int64
+}
+
+type cachedStreamMetadata struct {
+	metadata *StreamMetadata
+	updated  time.Time
+}
+
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	return &metadataCache{
+		ttl:     ttl,
+		streams: map[string]cachedStreamMetadata{},
+	}
+}
+
+// get returns a copy of the metadata if it is younger than maxAge, nil otherwise
+func (mc *metadataCache) get(stream string, maxAge time.Duration) *StreamMetadata {
+	if mc == nil || maxAge <= 0 {
+		return nil
+	}
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	cached, ok := mc.streams[stream]
+	if !ok {
+		return nil
+	}
+	age := time.Since(cached.updated)
+	if age > mc.ttl {
+		delete(mc.streams, stream)
+		return nil
+	}
+	if age > maxAge {
+		return nil
+	}
+	return cached.metadata.clone()
+}
+
+// put stores a copy of the metadata, a stream with an error or without leader is removed
+func (mc *metadataCache) put(metadata *StreamMetadata) {
+	if mc == nil || metadata == nil {
+		return
+	}
+	if metadata.responseCode != responseCodeOk || metadata.Leader == nil {
+		mc.invalidate(metadata.stream)
+		return
+	}
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.streams[metadata.stream] = cachedStreamMetadata{metadata: metadata.clone(), updated: time.Now()}
+}
+
+func (mc *metadataCache) invalidate(stream string) {
+	if mc == nil {
+		return
+	}
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	delete(mc.streams, stream)
+}
+
+// invalidateStreams removes the streams of the producers and consumers of a closed connection,
+// the leader or the replicas can be gone with the connection
+func (mc *metadataCache) invalidateStreams(streams []string) {
+	for _, stream := range streams {
+		mc.invalidate(stream)
+	}
+}
+
+func (mc *metadataCache) countRequest() {
+	if mc == nil {
+		return
+	}
+	atomic.AddInt64(&mc.requests, 1)
+}
+
+func (mc *metadataCache) size() int {
+	if mc == nil {
+		return 0
+	}
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return len(mc.streams)
+}
+
+// clone copies the brokers too, since the callers change them (see Broker.cloneFrom)
+func (sm *StreamMetadata) clone() *StreamMetadata {
+	cloneBroker := func(broker *Broker) *Broker {
+		if broker == nil {
+			return nil
+		}
+		copied := *broker
+		return &copied
+	}
+	replicas := make([]*Broker, 0, len(sm.Replicas))
+	for _, replica := range sm.Replicas {
+		replicas = append(replicas, cloneBroker(replica))
+	}
+	return StreamMetadata{}.New(sm.stream, sm.responseCode, cloneBroker(sm.Leader), replicas)
+}
+
+// cachedMetaDataCtx returns the metadata of the stream from the environment cache when the client
+// is a locator with the cache enabled, otherwise it queries the broker and fills the cache
+func (c *Client) cachedMetaDataCtx(ctx context.Context, stream string) *StreamsMetadata {
+	if c.metadataCache != nil {
+		if metadata := c.metadataCache.get(stream, c.metadataCache.ttl); metadata != nil {
+			streamsMetadata := StreamsMetadata{}.New()
+			streamsMetadata.items.Store(stream, metadata)
+			return streamsMetadata
+		}
+	}
+	c.metadataCache.countRequest()
+	streamsMetadata := c.metaDataCtx(ctx, stream)
+	if streamsMetadata != nil {
+		c.metadataCache.put(streamsMetadata.Get(stream))
+	}
+	return streamsMetadata
+}
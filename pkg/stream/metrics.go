@@ -0,0 +1,96 @@
+package stream
+
+import "time"
+
+// MetricsCollector receives the metrics of the producers, consumers and connections of an environment.
+// The methods are called from the hot path of the client (send, confirm, deliver), so they must be fast
+// and must not block. Embed NoOpMetricsCollector to implement only the metrics you need.
+// See EnvironmentOptions.SetMetricsCollector and the metrics package for an in-memory and expvar implementation
+type MetricsCollector interface {
+	// MessagePublished is called by Send/BatchSend for each message accepted by the producer
+	MessagePublished(producer *Producer, sizeBytes int)
+	// MessageConfirmed is called for each confirmed message with the time between the send and the confirmation
+	MessageConfirmed(producer *Producer, latency time.Duration)
+	// PublishError is called for each message rejected by the broker
+	PublishError(producer *Producer, code uint16)
+	// ProducerQueueDepth reports the messages waiting to be sent and the messages waiting for the confirmation
+	ProducerQueueDepth(producer *Producer, pending int, unConfirmed int)
+	// ProducerClosed is called when the producer is removed from its connection (closed by the user, by the broker
+	// or with the connection), the queue depth of the producer is not reported anymore
+	ProducerClosed(producer *Producer)
+	// ChunkDelivered is called for each chunk received by the consumer
+	ChunkDelivered(consumer *Consumer, messages int, sizeBytes int)
+	// CreditGranted is called when the client gives credits to the broker for the consumer
+	CreditGranted(consumer *Consumer, credits int)
+	// ConsumerClosed is called when the consumer is removed from its connection (closed by the user, by the broker
+	// or with the connection), the credits it didn't use are not outstanding anymore
+	ConsumerClosed(consumer *Consumer)
+	// HeartbeatReceived is called for each heartbeat sent by the broker
+	HeartbeatReceived(connectionName string)
+	// HeartbeatMissed is called when the broker didn't send data within the heartbeat interval
+	HeartbeatMissed(connectionName string)
+	// Reconnection is called after each reconnection attempt, err is nil when the attempt succeeded
+	Reconnection(name string, err error)
+}
+
+// NoOpMetricsCollector is the default MetricsCollector, it does nothing
+type NoOpMetricsCollector struct {
+}
+
+func (n NoOpMetricsCollector) MessagePublished(_ *Producer, _ int) {
+}
+
+func (n NoOpMetricsCollector) MessageConfirmed(_ *Producer, _ time.Duration) {
+}
+
+func (n NoOpMetricsCollector) PublishError(_ *Producer, _ uint16) {
+}
+
+func (n NoOpMetricsCollector) ProducerQueueDepth(_ *Producer, _ int, _ int) {
+}
+
+func (n NoOpMetricsCollector) ProducerClosed(_ *Producer) {
+}
+
+func (n NoOpMetricsCollector) ChunkDelivered(_ *Consumer, _ int, _ int) {
+}
+
+func (n NoOpMetricsCollector) CreditGranted(_ *Consumer, _ int) {
+}
+
+func (n NoOpMetricsCollector) ConsumerClosed(_ *Consumer) {
+}
+
+func (n NoOpMetricsCollector) HeartbeatReceived(_ string) {
+}
+
+func (n NoOpMetricsCollector) HeartbeatMissed(_ string) {
+}
+
+func (n NoOpMetricsCollector) Reconnection(_ string, _ error) {
+}
+
+func (producer *Producer) reportQueueDepth() {
+	if _, noOp := producer.options.client.metrics.(NoOpMetricsCollector); noOp {
+		return
+	}
+	// ProducerClosed was called or is about to be called
+	if producer.getStatus() == closed {
+		return
+	}
+	producer.options.client.metrics.ProducerQueueDepth(producer, producer.lenPendingMessages(), producer.lenUnConfirmed())
+}
+
+func (producer *Producer) reportClosed() {
+	if producer.options == nil || producer.options.client == nil {
+		return
+	}
+	producer.options.client.metrics.ProducerClosed(producer)
+}
+
+func (consumer *Consumer) reportClosed() {
+	if consumer.options == nil || consumer.options.client == nil {
+		return
+	}
+	consumer.options.client.metrics.ConsumerClosed(consumer)
+}
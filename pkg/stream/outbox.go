@@ -0,0 +1,350 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
+)
+
+// OutboxStore keeps the messages of a producer until the broker confirms them, see ProducerOptions.SetOutbox.
+// The messages are appended before they are sent, removed when they are confirmed and
+// replayed with the same publishing ids when the producer is created again.
+// A store must be used by one producer at a time
+type OutboxStore interface {
+	// Append stores the encoded message, an existing publishing id is replaced
+	Append(publishingId int64, data []byte) error
+	// Remove deletes the confirmed messages, the unknown ids are ignored
+	Remove(publishingIds []int64) error
+	// Pending returns the messages not removed, sorted by publishing id
+	Pending() ([]OutboxEntry, error)
+}
+
+type OutboxEntry struct {
+	PublishingId int64
+	Data         []byte // the message encoded with MarshalBinary
+}
+
+const (
+	outboxRecordAppend = byte(1)
+	outboxRecordRemove = byte(2)
+	// type, publishing id, data length
+	outboxRecordHeaderSize = 1 + 8 + 4
+
+	// the file is compacted when it has at least outboxCompactMinRecords obsolete records
+	// and they are more than outboxCompactRatio times the pending messages
+	outboxCompactMinRecords = 1024
+	outboxCompactRatio      = 2
+)
+
+// FileOutbox is an OutboxStore on an append-only local file.
+// Append syncs the file, so a message is on disk before it is sent.
+// Remove doesn't sync: after a crash a removed message can be replayed again,
+// the broker drops it with the deduplication.
+// The file is compacted when it is opened and when the obsolete records are more than
+// outboxCompactRatio times the pending messages, a truncated record at the end
+// (ex: crash during a write) is ignored
+type FileOutbox struct {
+	mutex   sync.Mutex
+	path    string
+	file    *os.File
+	pending map[int64][]byte
+	// obsolete counts the records of the file that are not pending messages:
+	// the remove records, the removed and the replaced appends
+	obsolete          int
+	compactMinRecords int
+}
+
+// NewFileOutbox opens or creates the outbox file
+func NewFileOutbox(path string) (*FileOutbox, error) {
+	outbox := &FileOutbox{path: path, pending: map[int64][]byte{}, compactMinRecords: outboxCompactMinRecords}
+	if err := outbox.load(); err != nil {
+		return nil, err
+	}
+	if err := outbox.compact(); err != nil {
+		return nil, err
+	}
+	return outbox, nil
+}
+
+func (o *FileOutbox) load() error {
+	file, err := os.Open(o.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	header := make([]byte, outboxRecordHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			// EOF or truncated header
+			return nil
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header[9:13])+4)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil
+		}
+		checksum := binary.BigEndian.Uint32(data[len(data)-4:])
+		data = data[:len(data)-4]
+		if crc32.Update(crc32.ChecksumIEEE(header), crc32.IEEETable, data) != checksum {
+			return nil
+		}
+		publishingId := int64(binary.BigEndian.Uint64(header[1:9]))
+		switch header[0] {
+		case outboxRecordAppend:
+			o.pending[publishingId] = data
+		case outboxRecordRemove:
+			delete(o.pending, publishingId)
+		default:
+			return fmt.Errorf("outbox %s: unknown record type %d", o.path, header[0])
+		}
+	}
+}
+
+// compact rewrites the pending messages in a new file and keeps it open to append
+func (o *FileOutbox) compact() error {
+	if o.file != nil {
+		if err := o.file.Close(); err != nil {
+			return err
+		}
+		o.file = nil
+	}
+	temporary := o.path + ".tmp"
+	file, err := os.OpenFile(temporary, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range o.sortedPending() {
+		if _, err := writer.Write(outboxRecord(outboxRecordAppend, entry.PublishingId, entry.Data)); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary, o.path); err != nil {
+		return err
+	}
+	o.file, err = os.OpenFile(o.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	o.obsolete = 0
+	return nil
+}
+
+// maybeCompact compacts the file when there are too many obsolete records.
+// A failure is returned, the records are still in the file so nothing is lost
+func (o *FileOutbox) maybeCompact() error {
+	if o.obsolete < o.compactMinRecords || o.obsolete <= outboxCompactRatio*len(o.pending) {
+		return nil
+	}
+	if err := o.compact(); err != nil {
+		return fmt.Errorf("outbox %s compaction: %w", o.path, err)
+	}
+	return nil
+}
+
+func outboxRecord(recordType byte, publishingId int64, data []byte) []byte {
+	record := make([]byte, outboxRecordHeaderSize, outboxRecordHeaderSize+len(data)+4)
+	record[0] = recordType
+	binary.BigEndian.PutUint64(record[1:9], uint64(publishingId))
+	binary.BigEndian.PutUint32(record[9:13], uint32(len(data)))
+	record = append(record, data...)
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(record))
+	return append(record, checksum...)
+}
+
+func (o *FileOutbox) Append(publishingId int64, data []byte) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.file == nil {
+		return AlreadyClosed
+	}
+	if _, err := o.file.Write(outboxRecord(outboxRecordAppend, publishingId, data)); err != nil {
+		return err
+	}
+	if err := o.file.Sync(); err != nil {
+		return err
+	}
+	if _, replaced := o.pending[publishingId]; replaced {
+		o.obsolete++
+	}
+	o.pending[publishingId] = data
+	return nil
+}
+
+func (o *FileOutbox) Remove(publishingIds []int64) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.file == nil {
+		return AlreadyClosed
+	}
+	var records []byte
+	for _, publishingId := range publishingIds {
+		if _, ok := o.pending[publishingId]; ok {
+			records = append(records, outboxRecord(outboxRecordRemove, publishingId, nil)...)
+			delete(o.pending, publishingId)
+			// the append and the remove record
+			o.obsolete += 2
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	if _, err := o.file.Write(records); err != nil {
+		return err
+	}
+	return o.maybeCompact()
+}
+
+func (o *FileOutbox) Pending() ([]OutboxEntry, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.sortedPending(), nil
+}
+
+func (o *FileOutbox) sortedPending() []OutboxEntry {
+	entries := make([]OutboxEntry, 0, len(o.pending))
+	for publishingId, data := range o.pending {
+		entries = append(entries, OutboxEntry{PublishingId: publishingId, Data: data})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].PublishingId < entries[j].PublishingId
+	})
+	return entries
+}
+
+// Len returns the number of messages not confirmed
+func (o *FileOutbox) Len() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.pending)
+}
+
+func (o *FileOutbox) Close() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.file == nil {
+		return AlreadyClosed
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+// outboxAppend stores the message before it is sent
+func (producer *Producer) outboxAppend(publishingId int64, messageBytes []byte) error {
+	if producer.options.Outbox == nil {
+		return nil
+	}
+	if err := producer.options.Outbox.Append(publishingId, messageBytes); err != nil {
+		return fmt.Errorf("outbox append: %w", err)
+	}
+	return nil
+}
+
+// outboxRemove removes the confirmed messages, or the messages not sent.
+// A failure is only logged: the message is replayed and dropped by the deduplication
+func (producer *Producer) outboxRemove(publishingIds []int64) {
+	if producer.options.Outbox == nil || len(publishingIds) == 0 {
+		return
+	}
+	if err := producer.options.Outbox.Remove(publishingIds); err != nil {
+		producer.options.client.logger.Warn("Can't remove the confirmed messages from the outbox",
+			"producer_id", producer.id, "error", err)
+	}
+}
+
+// replayOutbox sends again the messages not confirmed before, with their publishing ids.
+// The replay blocks until there is room whatever the BackPressurePolicy, so an outbox bigger than
+// MaxUnConfirmed, QueueSize or the rate limit is drained. It must run outside the locks of the
+// environment coordinators. The next publishing ids start after the replayed ones
+func (producer *Producer) replayOutbox() error {
+	if producer.options.Outbox == nil {
+		return nil
+	}
+	entries, err := producer.options.Outbox.Pending()
+	if err != nil {
+		return fmt.Errorf("outbox pending: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if last := entries[len(entries)-1].PublishingId; last > atomic.LoadInt64(&producer.sequence) {
+		atomic.StoreInt64(&producer.sequence, last)
+	}
+	producer.options.client.logger.Info("Replaying the outbox", "producer_id", producer.id,
+		"producer_name", producer.options.Name, "messages", len(entries))
+	for _, entry := range entries {
+		if err := producer.replayEntry(entry); err != nil {
+			return fmt.Errorf("outbox publishing id %d: %w", entry.PublishingId, err)
+		}
+	}
+	return nil
+}
+
+// replayEntry is like sendBytes with the BackPressureBlock policy,
+// the message is not appended since it is already in the outbox
+func (producer *Producer) replayEntry(entry OutboxEntry) error {
+	if producer.isStopped() {
+		return ErrShuttingDown
+	}
+	streamMessage := amqp.NewMessage(nil)
+	if err := streamMessage.UnmarshalBinary(entry.Data); err != nil {
+		return err
+	}
+	streamMessage.SetPublishingId(entry.PublishingId)
+
+	filterValue := ""
+	if producer.options.IsFilterEnabled() {
+		filterValue = producer.options.Filter.FilterValue(streamMessage)
+	}
+	if err := producer.waitForRoomWith(BackPressureBlock, 1); err != nil {
+		return err
+	}
+	if err := producer.limitRateWith(BackPressureBlock, 1, len(entry.Data)); err != nil {
+		return err
+	}
+	if producer.getStatus() != open {
+		producer.releaseRate(1, len(entry.Data))
+		return fmt.Errorf("%w: producer id: %d", ErrProducerClosed, producer.id)
+	}
+	producer.addUnConfirmed(entry.PublishingId, streamMessage, producer.id, nil)
+	if err := producer.enqueueWith(BackPressureBlock, messageSequence{
+		messageBytes:     entry.Data,
+		unCompressedSize: len(entry.Data),
+		publishingId:     entry.PublishingId,
+		filterValue:      filterValue,
+	}); err != nil {
+		producer.removeUnConfirmed(entry.PublishingId)
+		producer.releaseRate(1, len(entry.Data))
+		return err
+	}
+
+	producer.options.client.metrics.MessagePublished(producer, len(entry.Data))
+	producer.reportQueueDepth()
+	return nil
+}
@@ -0,0 +1,156 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
+)
+
+var _ = Describe("Outbox", func() {
+
+	It("File outbox", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "outbox")
+		outbox, err := NewFileOutbox(path)
+		Expect(err).NotTo(HaveOccurred())
+		for i := int64(1); i <= 5; i++ {
+			Expect(outbox.Append(i, []byte{byte(i)})).To(Succeed())
+		}
+		Expect(outbox.Remove([]int64{2, 4, 10})).To(Succeed())
+		Expect(outbox.Close()).To(Succeed())
+
+		// a record truncated by a crash is ignored
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.Write([]byte{outboxRecordAppend, 0, 0})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		outbox, err = NewFileOutbox(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outbox.Pending()).To(Equal([]OutboxEntry{
+			{PublishingId: 1, Data: []byte{1}},
+			{PublishingId: 3, Data: []byte{3}},
+			{PublishingId: 5, Data: []byte{5}},
+		}))
+		Expect(outbox.Close()).To(Succeed())
+		Expect(outbox.Append(6, nil)).To(Equal(AlreadyClosed))
+	})
+
+	It("File outbox compaction", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "outbox")
+		outbox, err := NewFileOutbox(path)
+		Expect(err).NotTo(HaveOccurred())
+		outbox.compactMinRecords = 10
+		fileSize := func() int64 {
+			info, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+			return info.Size()
+		}
+		recordSize := int64(len(outboxRecord(outboxRecordAppend, 1, []byte{1})))
+
+		Expect(outbox.Append(1, []byte{1})).To(Succeed())
+		for i := int64(2); i <= 6; i++ {
+			Expect(outbox.Append(i, []byte{byte(i)})).To(Succeed())
+			Expect(outbox.Remove([]int64{i})).To(Succeed())
+		}
+		// 10 obsolete records for one pending message
+		Expect(fileSize()).To(Equal(recordSize))
+
+		Expect(outbox.Append(7, []byte{7})).To(Succeed())
+		Expect(outbox.Close()).To(Succeed())
+		outbox, err = NewFileOutbox(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outbox.Pending()).To(Equal([]OutboxEntry{
+			{PublishingId: 1, Data: []byte{1}},
+			{PublishingId: 7, Data: []byte{7}},
+		}))
+		Expect(outbox.Close()).To(Succeed())
+	})
+
+	It("Replay an outbox bigger than the back-pressure limits", func() {
+		env, err := NewEnvironment(NewEnvironmentOptions().
+			SetRateLimiter(NewRateLimiter(200, 0)))
+		Expect(err).NotTo(HaveOccurred())
+		streamName := uuid.New().String()
+		Expect(env.DeclareStream(streamName, nil)).NotTo(HaveOccurred())
+		outbox, err := NewFileOutbox(filepath.Join(GinkgoT().TempDir(), "outbox"))
+		Expect(err).NotTo(HaveOccurred())
+		for i := int64(1); i <= 300; i++ {
+			data, err := amqp.NewMessage([]byte("payment")).MarshalBinary()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outbox.Append(i, data)).To(Succeed())
+		}
+
+		// the replay blocks, the policy applies to the messages sent after the replay
+		producer, err := env.NewProducer(streamName, NewProducerOptions().
+			SetProducerName("payments").
+			SetOutbox(outbox).
+			SetQueueSize(100).
+			SetMaxUnConfirmed(10).
+			SetBackPressure(BackPressureFailFast, 0))
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(outbox.Len, time.Second*10).Should(Equal(0))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+
+		Expect(outbox.Close()).To(Succeed())
+		Expect(env.DeleteStream(streamName)).NotTo(HaveOccurred())
+		Expect(env.Close()).NotTo(HaveOccurred())
+	})
+
+	It("Replay the messages not confirmed", func() {
+		env, err := NewEnvironment(nil)
+		Expect(err).NotTo(HaveOccurred())
+		streamName := uuid.New().String()
+		Expect(env.DeclareStream(streamName, nil)).NotTo(HaveOccurred())
+		outbox, err := NewFileOutbox(filepath.Join(GinkgoT().TempDir(), "outbox"))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = env.NewProducer(streamName, NewProducerOptions().SetOutbox(outbox))
+		Expect(err).To(HaveOccurred())
+
+		options := NewProducerOptions().SetProducerName("payments").SetOutbox(outbox)
+		producer, err := env.NewProducer(streamName, options)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 10; i++ {
+			Expect(producer.SendAndWait(context.Background(), amqp.NewMessage([]byte("payment")))).To(Succeed())
+		}
+		Expect(outbox.Len()).To(Equal(0))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+
+		// the process crashed before the confirmations: 9 and 10 are stored, 11 and 12 are not
+		for i := int64(9); i <= 12; i++ {
+			data, err := amqp.NewMessage([]byte("payment")).MarshalBinary()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outbox.Append(i, data)).To(Succeed())
+		}
+		producer, err = env.NewProducer(streamName, options)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(outbox.Len, time.Second*5).Should(Equal(0))
+		// the next publishing ids start after the replayed messages
+		future := producer.SendAsync(amqp.NewMessage([]byte("payment")))
+		Expect(future.PublishingId()).To(Equal(int64(13)))
+		_, err = future.Wait()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(producer.Close()).NotTo(HaveOccurred())
+
+		sequence, err := env.QuerySequence("payments", streamName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sequence).To(Equal(int64(13)))
+		Eventually(func() int64 {
+			stats, err := env.StreamStats(streamName)
+			Expect(err).NotTo(HaveOccurred())
+			offset, _ := stats.LastOffset()
+			return offset
+		}, time.Second*5).Should(Equal(int64(12)), "9 and 10 are dropped by the deduplication")
+
+		Expect(outbox.Close()).To(Succeed())
+		Expect(env.DeleteStream(streamName)).NotTo(HaveOccurred())
+		Expect(env.Close()).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// placeClient connects the client and checks that it reached one of the expected nodes.
+// Behind a load balancer (see AddressResolver) the connection can land on any node of the cluster,
+// so the client is closed and re-dialed until the advertised_host and advertised_port returned
+// by the broker match one of the expected nodes, or until maxPlacementAttempts.
+// See https://blog.rabbitmq.com/posts/2021/07/connecting-to-streams/
+// cc.mutexContext must be locked
+func (cc *environmentCoordinator) placeClient(ctx context.Context, client *Client, streamName string,
+	expected []*Broker, newClient func() *Client) (*Client, error) {
+	maxAttempts := cc.maxPlacementAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxPlacementAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		if err := client.connectCtx(ctx); err != nil {
+			// a client that failed to connect is not reused, the next producer or consumer gets a new one
+			_ = client.Close()
+			cc.removeClient(client)
+			return nil, err
+		}
+		if len(expected) == 0 || client.isConnectedTo(expected) {
+			return client, nil
+		}
+
+		reached := net.JoinHostPort(client.connectionProperties.host, client.connectionProperties.port)
+		cc.logger.Debug("The connection reached an unexpected node, retry",
+			"stream", streamName, "reached", reached, "expected", nodesName(expected),
+			"attempt", attempt, "max_attempts", maxAttempts)
+		_ = client.Close()
+		cc.removeClient(client)
+		if attempt >= maxAttempts {
+			return nil, fmt.Errorf("%w: stream %s, expected %s, the last connection reached %s after %d attempts",
+				ErrPlacementFailed, streamName, nodesName(expected), reached, attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(placementRetryDelay):
+		}
+		client = newClient()
+	}
+}
+
+// removeClient removes a client closed during the placement or after a failed connection,
+// cc.mutexContext must be locked
+func (cc *environmentCoordinator) removeClient(client *Client) {
+	for id, c := range cc.clientsPerContext {
+		if c == client {
+			delete(cc.clientsPerContext, id)
+		}
+	}
+}
+
+// isConnectedTo returns true if the node advertised by the connection is one of the nodes
+func (c *Client) isConnectedTo(nodes []*Broker) bool {
+	for _, node := range nodes {
+		if c.connectionProperties.host == node.advHost && c.connectionProperties.port == node.advPort {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlacementCandidate returns true if an existing client can be reused for the expected nodes.
+// A closed client is a candidate since placeClient reconnects and checks it
+func (c *Client) isPlacementCandidate(expected []*Broker) bool {
+	return len(expected) == 0 || !c.socket.isOpen() || c.isConnectedTo(expected)
+}
+
+// consumerCandidatesCtx returns the nodes where a consumer should be connected:
+// the replicas, or the leader when the stream has no replicas ready
+func (c *Client) consumerCandidatesCtx(ctx context.Context, stream string) ([]*Broker, error) {
+	streamsMetadata := c.cachedMetaDataCtx(ctx, stream)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if streamsMetadata == nil {
+		return nil, fmt.Errorf("leader error for stream: %s", stream)
+	}
+
+	streamMetadata := streamsMetadata.Get(stream)
+	if streamMetadata.responseCode != responseCodeOk {
+		return nil, lookErrorCode(streamMetadata.responseCode)
+	}
+	if streamMetadata.Leader == nil {
+		return nil, LeaderNotReady
+	}
+
+	var candidates []*Broker
+	for _, replica := range streamMetadata.Replicas {
+		if replica != nil {
+			candidates = append(candidates, replica)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, streamMetadata.Leader)
+	}
+	for _, candidate := range candidates {
+		candidate.advHost = candidate.Host
+		candidate.advPort = candidate.Port
+	}
+	return candidates, nil
+}
+
+func nodesName(nodes []*Broker) string {
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		names = append(names, net.JoinHostPort(node.advHost, node.advPort))
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/message"
 	"sync"
 	"sync/atomic"
@@ -20,6 +19,7 @@ type ConfirmationStatus struct {
 	err          error
 	errorCode    uint16
 	linkedTo     []*ConfirmationStatus
+	future       *PublishFuture // see SendAsync
 }
 
 func (cs *ConfirmationStatus) IsConfirmed() bool {
@@ -46,6 +46,11 @@ func (cs *ConfirmationStatus) GetMessage() message.StreamMessage {
 	return cs.message
 }
 
+// IsDropped is true when the message was dropped by BackPressureDropOldest, it was not sent
+func (cs *ConfirmationStatus) IsDropped() bool {
+	return cs.errorCode == droppedError
+}
+
 func (cs *ConfirmationStatus) GetErrorCode() uint16 {
 	return cs.errorCode
 }
@@ -60,6 +65,7 @@ type messageSequence struct {
 	unCompressedSize int
 	publishingId     int64
 	filterValue      string
+	flush            bool // send the pending messages without waiting BatchPublishingDelay
 }
 
 type Producer struct {
@@ -73,6 +79,13 @@ type Producer struct {
 	publishConfirm      chan []*ConfirmationStatus
 	closeHandler        chan Event
 	status              int
+	stopped             int32 // see Environment.Shutdown
+	// roomSignal is closed when an unconfirmed message is removed, see waitForRoomWith
+	roomSignal chan struct{}
+	// done is closed when the producer is closed, removed from its connection or stopped,
+	// it interrupts the waits of Send (see limitRateWith)
+	done     chan struct{}
+	doneOnce sync.Once
 
 	/// needed for the async publish
 	messageSequenceCh chan messageSequence
@@ -103,6 +116,12 @@ type ProducerOptions struct {
 	ConfirmationTimeOut  time.Duration   // Time to wait for the confirmation
 	ClientProvidedName   string          // Client provider name that will be shown in the management UI
 	Filter               *ProducerFilter // Enable the filter feature, by default is disabled. Pointer nil
+	// BackPressure is the policy when the queue is full or MaxUnConfirmed is reached, default BackPressureBlock
+	BackPressure        BackPressurePolicy
+	BackPressureTimeout time.Duration // the wait of BackPressureBlockTimeout
+	MaxUnConfirmed      int           // max messages waiting for the confirmation, 0 is no limit
+	RateLimiter         *RateLimiter  // limits the messages and the bytes per second, nil is no limit
+	Outbox              OutboxStore   // keeps the messages until they are confirmed, it requires Name
 }
 
 func (po *ProducerOptions) SetProducerName(name string) *ProducerOptions {
@@ -150,6 +169,85 @@ func (po *ProducerOptions) SetFilter(filter *ProducerFilter) *ProducerOptions {
 	return po
 }
 
+// SetBackPressure sets the policy, the timeout is used only by BackPressureBlockTimeout
+func (po *ProducerOptions) SetBackPressure(policy BackPressurePolicy, timeout time.Duration) *ProducerOptions {
+	po.BackPressure = policy
+	po.BackPressureTimeout = timeout
+	return po
+}
+
+func (po *ProducerOptions) SetMaxUnConfirmed(maxUnConfirmed int) *ProducerOptions {
+	po.MaxUnConfirmed = maxUnConfirmed
+	return po
+}
+
+// SetOutbox sets the store of the messages not confirmed yet, they are sent again with the same publishing ids
+// when the producer is created again with the same Name. The broker deduplication drops the messages already stored
+func (po *ProducerOptions) SetOutbox(outbox OutboxStore) *ProducerOptions {
+	po.Outbox = outbox
+	return po
+}
+
+// SetRateLimiter limits the publish rate of the producer, the limiter can be shared with other producers
+func (po *ProducerOptions) SetRateLimiter(limiter *RateLimiter) *ProducerOptions {
+	po.RateLimiter = limiter
+	return po
+}
+
+// Validate checks the options against the limits of the client.
+// It is called when the producer is created, the broker features (like the filter) are checked later
+func (po *ProducerOptions) Validate() error {
+	if po.isSubEntriesBatching() && po.IsFilterEnabled() {
+		return fmt.Errorf("sub-entry batching can't be enabled with filter")
+	}
+	if po.QueueSize < minQueuePublisherSize || po.QueueSize > maxQueuePublisherSize {
+		return fmt.Errorf("QueueSize values must be between %d and %d",
+			minQueuePublisherSize, maxQueuePublisherSize)
+	}
+
+	if po.BatchSize < minBatchSize || po.BatchSize > maxBatchSize {
+		return fmt.Errorf("BatchSize values must be between %d and %d",
+			minBatchSize, maxBatchSize)
+	}
+
+	if po.BatchPublishingDelay < minBatchPublishingDelay || po.BatchPublishingDelay > maxBatchPublishingDelay {
+		return fmt.Errorf("BatchPublishingDelay values must be between %d and %d",
+			minBatchPublishingDelay, maxBatchPublishingDelay)
+	}
+
+	if po.SubEntrySize < minSubEntrySize || po.SubEntrySize > maxSubEntrySize {
+		return fmt.Errorf("SubEntrySize values must be between %d and %d",
+			minSubEntrySize, maxSubEntrySize)
+	}
+
+	if !po.isSubEntriesBatching() {
+		if po.Compression.enabled {
+			return fmt.Errorf("sub-entry batching must be enabled to enable compression")
+		}
+	}
+
+	if !po.isSubEntriesBatching() {
+		if po.Compression.value != None && po.Compression.value != GZIP {
+			return fmt.Errorf("compression values valid are: %d (None) %d (Gzip)", None, GZIP)
+		}
+	}
+
+	if !po.BackPressure.isValid() {
+		return fmt.Errorf("BackPressure values valid are: %s, %s, %s, %s", BackPressureBlock,
+			BackPressureBlockTimeout, BackPressureFailFast, BackPressureDropOldest)
+	}
+	if po.BackPressure == BackPressureBlockTimeout && po.BackPressureTimeout <= 0 {
+		return fmt.Errorf("BackPressureTimeout must be positive with %s", BackPressureBlockTimeout)
+	}
+	if po.MaxUnConfirmed < 0 {
+		return fmt.Errorf("MaxUnConfirmed can't be negative")
+	}
+	if po.Outbox != nil && (po.Name == "" || po.isSubEntriesBatching()) {
+		return fmt.Errorf("the outbox requires the deduplication: a producer Name and no sub-entry batching")
+	}
+	return nil
+}
+
 func (po *ProducerOptions) IsFilterEnabled() bool {
 	return po.Filter != nil
 }
@@ -164,6 +262,7 @@ func NewProducerOptions() *ProducerOptions {
 		ConfirmationTimeOut:  defaultConfirmationTimeOut,
 		ClientProvidedName:   "go-stream-producer",
 		Filter:               nil,
+		BackPressure:         BackPressureBlock,
 	}
 }
 
@@ -173,15 +272,20 @@ func (producer *Producer) GetUnConfirmed() map[int64]*ConfirmationStatus {
 	return producer.unConfirmedMessages
 }
 
-func (producer *Producer) addUnConfirmed(sequence int64, message message.StreamMessage, producerID uint8) {
+func (producer *Producer) addUnConfirmed(sequence int64, message message.StreamMessage, producerID uint8,
+	future *PublishFuture) {
 	producer.mutex.Lock()
 	defer producer.mutex.Unlock()
+	if future != nil {
+		future.publishingId = sequence
+	}
 	producer.unConfirmedMessages[sequence] = &ConfirmationStatus{
 		inserted:     time.Now(),
 		message:      message,
 		producerID:   producerID,
 		publishingId: sequence,
 		confirmed:    false,
+		future:       future,
 	}
 }
 
@@ -193,6 +297,7 @@ func (producer *Producer) removeUnConfirmed(sequence int64) {
 	producer.mutex.Lock()
 	defer producer.mutex.Unlock()
 	delete(producer.unConfirmedMessages, sequence)
+	producer.signalRoomLocked()
 }
 
 func (producer *Producer) lenUnConfirmed() int {
@@ -234,8 +339,19 @@ func (producer *Producer) GetBroker() *Broker {
 }
 func (producer *Producer) setStatus(status int) {
 	producer.mutex.Lock()
-	defer producer.mutex.Unlock()
 	producer.status = status
+	producer.mutex.Unlock()
+	if status == closed {
+		producer.signalDone()
+	}
+}
+
+func (producer *Producer) signalDone() {
+	producer.doneOnce.Do(func() {
+		if producer.done != nil {
+			close(producer.done)
+		}
+	})
 }
 
 func (producer *Producer) getStatus() int {
@@ -267,10 +383,12 @@ func (producer *Producer) startUnconfirmedMessagesTimeOutTask() {
 					msg.err = ConfirmationTimoutError
 					msg.errorCode = timeoutError
 					msg.confirmed = false
+					msg.resolveFuture()
 					if producer.publishConfirm != nil {
 						producer.publishConfirm <- []*ConfirmationStatus{msg}
 					}
 					delete(producer.unConfirmedMessages, msg.publishingId)
+					producer.signalRoomLocked()
 				}
 			}
 			producer.mutex.Unlock()
@@ -311,7 +429,7 @@ func (producer *Producer) startPublishTask() {
 
 					producer.pendingMessages.size += msg.unCompressedSize
 					producer.pendingMessages.messages = append(producer.pendingMessages.messages, msg)
-					if len(producer.pendingMessages.messages) >= (producer.options.BatchSize) {
+					if msg.flush || len(producer.pendingMessages.messages) >= (producer.options.BatchSize) {
 						producer.sendBufferedMessages()
 					}
 					producer.mutexPending.Unlock()
@@ -328,6 +446,14 @@ func (producer *Producer) startPublishTask() {
 }
 
 func (producer *Producer) sendBytes(streamMessage message.StreamMessage, messageBytes []byte) error {
+	return producer.sendBytesWithFuture(streamMessage, messageBytes, nil, false)
+}
+
+func (producer *Producer) sendBytesWithFuture(streamMessage message.StreamMessage, messageBytes []byte,
+	future *PublishFuture, flush bool) error {
+	if producer.isStopped() {
+		return ErrShuttingDown
+	}
 	if len(messageBytes)+initBufferPublishSize > producer.options.client.getTuneState().requestedMaxFrameSize {
 		return FrameTooLarge
 	}
@@ -337,24 +463,52 @@ func (producer *Producer) sendBytes(streamMessage message.StreamMessage, message
 		filterValue = producer.options.Filter.FilterValue(streamMessage)
 	}
 
+	if err := producer.waitForRoom(1); err != nil {
+		return err
+	}
+	if err := producer.limitRate(1, len(messageBytes)); err != nil {
+		return err
+	}
 	sequence := producer.assignPublishingID(streamMessage)
-	producer.addUnConfirmed(sequence, streamMessage, producer.id)
+	if err := producer.outboxAppend(sequence, messageBytes); err != nil {
+		producer.releaseRate(1, len(messageBytes))
+		return err
+	}
+	producer.addUnConfirmed(sequence, streamMessage, producer.id, future)
 
 	if producer.getStatus() == open {
-		producer.messageSequenceCh <- messageSequence{
+		err := producer.enqueue(messageSequence{
 			messageBytes:     messageBytes,
 			unCompressedSize: len(messageBytes),
 			publishingId:     sequence,
 			filterValue:      filterValue,
+			flush:            flush,
+		})
+		if err != nil {
+			producer.abortSend(sequence, len(messageBytes), future)
+			return err
 		}
 	} else {
-		// TODO: Change the error message with a typed error
-		return fmt.Errorf("producer id: %d  closed", producer.id)
+		producer.abortSend(sequence, len(messageBytes), future)
+		return fmt.Errorf("%w: producer id: %d", ErrProducerClosed, producer.id)
 	}
 
+	producer.options.client.metrics.MessagePublished(producer, len(messageBytes))
+	producer.reportQueueDepth()
 	return nil
 }
 
+// abortSend cleans up a message not sent: the unconfirmed entry, the outbox and the rate limiter tokens.
+// The future is not resolved, its publishing id is -1
+func (producer *Producer) abortSend(sequence int64, size int, future *PublishFuture) {
+	producer.removeUnConfirmed(sequence)
+	producer.outboxRemove([]int64{sequence})
+	producer.releaseRate(1, size)
+	if future != nil {
+		future.publishingId = -1
+	}
+}
+
 func (producer *Producer) Send(streamMessage message.StreamMessage) error {
 	messageBytes, err := streamMessage.MarshalBinary()
 	if err != nil {
@@ -373,20 +527,44 @@ func (producer *Producer) assignPublishingID(message message.StreamMessage) int6
 }
 
 func (producer *Producer) BatchSend(batchMessages []message.StreamMessage) error {
+	return producer.batchSend(batchMessages, nil)
+}
+
+// batchSend sends the messages, futures is nil or has a future for each message
+func (producer *Producer) batchSend(batchMessages []message.StreamMessage, futures []*PublishFuture) error {
+	if producer.isStopped() {
+		return ErrShuttingDown
+	}
+	if err := producer.waitForRoom(len(batchMessages)); err != nil {
+		return err
+	}
 	var messagesSequence = make([]messageSequence, len(batchMessages))
+	var messagesBytes = make([][]byte, len(batchMessages))
 	totalBufferToSend := 0
 	for i, batchMessage := range batchMessages {
 		messageBytes, err := batchMessage.MarshalBinary()
 		if err != nil {
 			return err
 		}
+		messagesBytes[i] = messageBytes
+		totalBufferToSend += len(messageBytes)
+	}
+	// the batch too large is not sent, it doesn't wait for the rate limiter
+	frameTooLarge := totalBufferToSend+initBufferPublishSize > producer.options.client.tuneState.requestedMaxFrameSize
+	if !frameTooLarge {
+		if err := producer.limitRate(len(batchMessages), totalBufferToSend); err != nil {
+			return err
+		}
+	}
+
+	for i, batchMessage := range batchMessages {
+		messageBytes := messagesBytes[i]
 		filterValue := ""
 		if producer.options.IsFilterEnabled() {
 			filterValue = producer.options.Filter.FilterValue(batchMessage)
 		}
 
 		sequence := producer.assignPublishingID(batchMessage)
-		totalBufferToSend += len(messageBytes)
 		messagesSequence[i] = messageSequence{
 			messageBytes:     messageBytes,
 			unCompressedSize: len(messageBytes),
@@ -394,18 +572,37 @@ func (producer *Producer) BatchSend(batchMessages []message.StreamMessage) error
 			filterValue:      filterValue,
 		}
 
-		producer.addUnConfirmed(sequence, batchMessage, producer.id)
+		if !frameTooLarge {
+			if err := producer.outboxAppend(sequence, messageBytes); err != nil {
+				// the previous messages of the batch are not sent
+				var appended []int64
+				for _, msg := range messagesSequence[:i] {
+					producer.removeUnConfirmed(msg.publishingId)
+					appended = append(appended, msg.publishingId)
+				}
+				producer.outboxRemove(appended)
+				producer.releaseRate(len(batchMessages), totalBufferToSend)
+				return err
+			}
+		}
+
+		var future *PublishFuture
+		if futures != nil {
+			future = futures[i]
+		}
+		producer.addUnConfirmed(sequence, batchMessage, producer.id, future)
 	}
 
-	if totalBufferToSend+initBufferPublishSize > producer.options.client.tuneState.requestedMaxFrameSize {
+	if frameTooLarge {
 		for _, msg := range messagesSequence {
 
 			unConfirmedMessage := producer.getUnConfirmed(msg.publishingId)
 
 			//producer.mutex.Lock()
+			unConfirmedMessage.err = FrameTooLarge
+			unConfirmedMessage.errorCode = responseCodeFrameTooLarge
+			unConfirmedMessage.resolveFuture()
 			if producer.publishConfirm != nil {
-				unConfirmedMessage.err = FrameTooLarge
-				unConfirmedMessage.errorCode = responseCodeFrameTooLarge
 				producer.publishConfirm <- []*ConfirmationStatus{unConfirmedMessage}
 			}
 			//producer.mutex.Unlock()
@@ -415,7 +612,16 @@ func (producer *Producer) BatchSend(batchMessages []message.StreamMessage) error
 		return FrameTooLarge
 	}
 
-	return producer.internalBatchSend(messagesSequence)
+	err := producer.internalBatchSend(messagesSequence)
+	if err != nil {
+		producer.releaseRate(len(batchMessages), totalBufferToSend)
+		return err
+	}
+	for _, msg := range messagesSequence {
+		producer.options.client.metrics.MessagePublished(producer, msg.unCompressedSize)
+	}
+	producer.reportQueueDepth()
+	return nil
 }
 
 func (producer *Producer) GetID() uint8 {
@@ -487,7 +693,7 @@ func (producer *Producer) aggregateEntities(msgs []messageSequence, size int, co
 		}
 	}
 
-	compressByValue(compression.value).Compress(&subEntries)
+	compressByValue(compression.value, producer.options.client.logger).Compress(&subEntries)
 
 	return subEntries, nil
 }
@@ -499,7 +705,7 @@ func (producer *Producer) internalBatchSendProdId(messagesSequence []messageSequ
 	producer.options.client.socket.mutex.Lock()
 	defer producer.options.client.socket.mutex.Unlock()
 	if producer.getStatus() == closed {
-		return fmt.Errorf("producer id: %d closed", producer.id)
+		return fmt.Errorf("%w: producer id: %d", ErrProducerClosed, producer.id)
 	}
 
 	if producer.options.IsFilterEnabled() &&
@@ -550,7 +756,7 @@ func (producer *Producer) internalBatchSendProdId(messagesSequence []messageSequ
 
 	err := producer.options.client.socket.writer.Flush() //writeAndFlush(b.Bytes())
 	if err != nil {
-		logs.LogError("Producer BatchSend error during flush: %s", err)
+		producer.options.client.logger.Error("Producer BatchSend error during flush", "producer_id", producer.id, "error", err)
 		producer.setStatus(closed)
 		return err
 	}
@@ -564,11 +770,13 @@ func (producer *Producer) flushUnConfirmedMessages(errorCode uint16, err error)
 		msg.confirmed = false
 		msg.err = err
 		msg.errorCode = errorCode
+		msg.resolveFuture()
 		if producer.publishConfirm != nil {
 			producer.publishConfirm <- []*ConfirmationStatus{msg}
 		}
 		delete(producer.unConfirmedMessages, msg.publishingId)
 	}
+	producer.signalRoomLocked()
 
 	producer.mutex.Unlock()
 }
@@ -590,12 +798,12 @@ func (producer *Producer) Close() error {
 
 	err := producer.options.client.deletePublisher(producer.id)
 	if err != nil {
-		logs.LogError("error delete Publisher on closing: %s", err)
+		producer.options.client.logger.Error("Error delete Publisher on closing", "producer_id", producer.id, "error", err)
 	}
 	if producer.options.client.coordinator.ProducersCount() == 0 {
 		err := producer.options.client.Close()
 		if err != nil {
-			logs.LogError("error during closing client: %s", err)
+			producer.options.client.logger.Error("Error during closing client", "error", err)
 		}
 	}
 
@@ -621,9 +829,9 @@ func (producer *Producer) waitForInflightMessages() {
 	tentatives := 0
 
 	for (channelLength > 0 || pendingMessagesLen > 0 || producer.lenUnConfirmed() > 0) && tentatives < 3 {
-		logs.LogDebug("waitForInflightMessages, channel: %d - pending messages len: %d - unconfirmed len: %d - retry: %d",
-			channelLength, pendingMessagesLen,
-			producer.lenUnConfirmed(), tentatives)
+		producer.options.client.logger.Debug("waitForInflightMessages",
+			"channel", channelLength, "pending_messages", pendingMessagesLen,
+			"unconfirmed", producer.lenUnConfirmed(), "retry", tentatives)
 		time.Sleep(time.Duration(2*producer.options.BatchPublishingDelay) * time.Millisecond)
 		channelLength = len(producer.messageSequenceCh)
 		pendingMessagesLen = producer.lenPendingMessages()
@@ -696,7 +904,7 @@ func (c *Client) deletePublisher(publisherId byte) error {
 		Err:     nil,
 	})
 	if err != nil {
-		logs.LogWarn("producer id: %d already removed", publisherId)
+		c.logger.Warn("Producer already removed", "producer_id", publisherId)
 	}
 
 	return errWrite.Err
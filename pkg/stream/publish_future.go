@@ -0,0 +1,163 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/message"
+)
+
+// PublishFuture is the confirmation of a message sent with SendAsync or BatchSendAsync.
+// It is resolved with the ConfirmationStatus when the broker confirms the message, or with the error:
+// the publish error returned by the broker, ConfirmationTimoutError, ConnectionClosed,
+// or the error of the send (ex: FrameTooLarge, ErrShuttingDown).
+// The futures don't need NotifyPublishConfirmation, if it is set the confirmations are sent on the channel too
+type PublishFuture struct {
+	publishingId int64
+	done         chan struct{}
+	once         sync.Once
+	status       *ConfirmationStatus
+	err          error
+}
+
+func newPublishFuture() *PublishFuture {
+	return &PublishFuture{publishingId: -1, done: make(chan struct{})}
+}
+
+// Done is closed when the future is resolved, it can be used in a select
+func (f *PublishFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the future is resolved. The status is nil when the message was not sent
+func (f *PublishFuture) Wait() (*ConfirmationStatus, error) {
+	<-f.done
+	return f.status, f.err
+}
+
+// WaitCtx is like Wait but it returns ctx.Err() when the context is done before,
+// the future can be waited again
+func (f *PublishFuture) WaitCtx(ctx context.Context) (*ConfirmationStatus, error) {
+	select {
+	case <-f.done:
+		return f.status, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PublishingId is the publishing id assigned to the message, -1 when the message was not sent
+func (f *PublishFuture) PublishingId() int64 {
+	return f.publishingId
+}
+
+func (f *PublishFuture) resolve(status *ConfirmationStatus, err error) {
+	f.once.Do(func() {
+		f.status = status
+		f.err = err
+		close(f.done)
+	})
+}
+
+// resolveFuture resolves the future of the message, if any, with the current status
+func (cs *ConfirmationStatus) resolveFuture() {
+	if cs == nil || cs.future == nil {
+		return
+	}
+	if cs.confirmed {
+		cs.future.resolve(cs, nil)
+	} else {
+		cs.future.resolve(cs, cs.err)
+	}
+}
+
+// SendAsync sends the message like Send and returns the future of its confirmation
+func (producer *Producer) SendAsync(streamMessage message.StreamMessage) *PublishFuture {
+	return producer.sendAsync(streamMessage, false)
+}
+
+func (producer *Producer) sendAsync(streamMessage message.StreamMessage, flush bool) *PublishFuture {
+	future := newPublishFuture()
+	messageBytes, err := streamMessage.MarshalBinary()
+	if err != nil {
+		future.resolve(nil, err)
+		return future
+	}
+	if err := producer.sendBytesWithFuture(streamMessage, messageBytes, future, flush); err != nil {
+		future.resolve(nil, err)
+	}
+	return future
+}
+
+// BatchSendAsync sends the messages like BatchSend and returns a future for each message, in the same order.
+// When the batch can't be sent all the futures are resolved with the error
+func (producer *Producer) BatchSendAsync(batchMessages []message.StreamMessage) []*PublishFuture {
+	futures := make([]*PublishFuture, len(batchMessages))
+	for i := range futures {
+		futures[i] = newPublishFuture()
+	}
+	if err := producer.batchSend(batchMessages, futures); err != nil {
+		for _, future := range futures {
+			future.resolve(nil, err)
+		}
+	}
+	return futures
+}
+
+// PublishRejectedError is returned by SendAndWait and BatchSendAndWait when the broker
+// rejects the message, Code is the response code of the publish error
+type PublishRejectedError struct {
+	PublishingId int64
+	Code         uint16
+	Err          error
+}
+
+func (e *PublishRejectedError) Error() string {
+	return fmt.Sprintf("publishing id %d rejected by the broker, code %d: %s", e.PublishingId, e.Code, e.Err)
+}
+
+func (e *PublishRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// SendAndWait sends the message without waiting BatchPublishingDelay and blocks until it is confirmed.
+// The error is:
+//   - *PublishRejectedError when the broker rejects the message
+//   - ConfirmationTimoutError (see ProducerOptions.SetConfirmationTimeOut) or ctx.Err() when the context is done
+//   - ConnectionClosed when the connection is lost or the producer is closed before the confirmation
+//   - the send error (ex: FrameTooLarge, ProducerQueueFull) or MessageDropped (see BackPressureDropOldest)
+func (producer *Producer) SendAndWait(ctx context.Context, streamMessage message.StreamMessage) error {
+	return waitFuture(ctx, producer.sendAsync(streamMessage, true))
+}
+
+// BatchSendAndWait sends the messages like BatchSend and blocks until all of them are confirmed.
+// It returns the error of the first message not confirmed, see SendAndWait for the errors
+func (producer *Producer) BatchSendAndWait(ctx context.Context, batchMessages []message.StreamMessage) error {
+	var result error
+	for _, future := range producer.BatchSendAsync(batchMessages) {
+		if err := waitFuture(ctx, future); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
+
+func waitFuture(ctx context.Context, future *PublishFuture) error {
+	status, err := future.WaitCtx(ctx)
+	if err == nil || status == nil {
+		return err
+	}
+	switch {
+	case errors.Is(err, ConfirmationTimoutError), errors.Is(err, ConnectionClosed), errors.Is(err, FrameTooLarge),
+		errors.Is(err, MessageDropped):
+		return err
+	}
+	return &PublishRejectedError{PublishingId: status.GetPublishingId(), Code: status.GetErrorCode(), Err: err}
+}
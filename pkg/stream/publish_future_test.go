@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
+)
+
+var _ = Describe("Publish futures", func() {
+	var (
+		env        *Environment
+		streamName string
+	)
+
+	BeforeEach(func() {
+		var err error
+		env, err = NewEnvironment(nil)
+		Expect(err).NotTo(HaveOccurred())
+		streamName = uuid.New().String()
+		Expect(env.DeclareStream(streamName, nil)).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(env.DeleteStream(streamName)).NotTo(HaveOccurred())
+		Expect(env.Close()).NotTo(HaveOccurred())
+	})
+
+	It("SendAsync", func() {
+		producer, err := env.NewProducer(streamName, nil)
+		Expect(err).NotTo(HaveOccurred())
+		future := producer.SendAsync(amqp.NewMessage([]byte("hello")))
+		// the publishing ids start from 1
+		Expect(future.PublishingId()).To(Equal(int64(1)))
+		Eventually(future.Done(), time.Second*5).Should(BeClosed())
+		status, err := future.Wait()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.IsConfirmed()).To(BeTrue())
+		Expect(status.GetPublishingId()).To(Equal(int64(1)))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+	})
+
+	It("BatchSendAsync with sub-entries", func() {
+		producer, err := env.NewProducer(streamName, NewProducerOptions().SetSubEntrySize(10))
+		Expect(err).NotTo(HaveOccurred())
+		futures := producer.BatchSendAsync(CreateArrayMessagesForTesting(25))
+		Expect(futures).To(HaveLen(25))
+		for i, future := range futures {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			status, err := future.WaitCtx(ctx)
+			cancel()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.IsConfirmed()).To(BeTrue())
+			Expect(future.PublishingId()).To(Equal(int64(i + 1)))
+		}
+		Expect(producer.Close()).NotTo(HaveOccurred())
+	})
+
+	It("Send errors", func() {
+		producer, err := env.NewProducer(streamName, nil)
+		Expect(err).NotTo(HaveOccurred())
+		status, err := producer.SendAsync(amqp.NewMessage(make([]byte, 2_000_000))).Wait()
+		Expect(err).To(Equal(FrameTooLarge))
+		Expect(status).To(BeNil())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = newPublishFuture().WaitCtx(ctx)
+		Expect(err).To(Equal(context.Canceled))
+
+		futures := producer.BatchSendAsync(CreateArrayMessagesForTesting(3))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+		// the futures are resolved, confirmed or not, when the producer is closed
+		for _, future := range futures {
+			Eventually(future.Done(), time.Second*5).Should(BeClosed())
+		}
+
+		future := producer.SendAsync(amqp.NewMessage([]byte("closed")))
+		_, err = future.Wait()
+		Expect(err).To(MatchError(ErrProducerClosed))
+		// the message is not waiting for a confirmation
+		Expect(future.PublishingId()).To(Equal(int64(-1)))
+		Expect(producer.lenUnConfirmed()).To(BeZero())
+	})
+
+	It("SendAndWait flushes without BatchPublishingDelay", func() {
+		producer, err := env.NewProducer(streamName, NewProducerOptions().SetBatchPublishingDelay(500))
+		Expect(err).NotTo(HaveOccurred())
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		Expect(producer.SendAndWait(ctx, amqp.NewMessage([]byte("audit")))).NotTo(HaveOccurred())
+		Expect(producer.BatchSendAndWait(ctx, CreateArrayMessagesForTesting(10))).NotTo(HaveOccurred())
+		Expect(producer.SendAndWait(ctx, amqp.NewMessage(make([]byte, 2_000_000)))).To(Equal(FrameTooLarge))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+	})
+
+	It("SendAndWait errors", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		Expect(waitFuture(ctx, newPublishFuture())).To(Equal(context.DeadlineExceeded))
+
+		resolved := func(code uint16, err error) *PublishFuture {
+			future := newPublishFuture()
+			future.resolve(&ConfirmationStatus{publishingId: 7, errorCode: code, err: err}, err)
+			return future
+		}
+		err := waitFuture(context.Background(), resolved(responseCodePublisherDoesNotExist, PublisherDoesNotExist))
+		var rejected *PublishRejectedError
+		Expect(errors.As(err, &rejected)).To(BeTrue())
+		Expect(rejected.PublishingId).To(Equal(int64(7)))
+		Expect(rejected.Code).To(Equal(responseCodePublisherDoesNotExist))
+		Expect(errors.Is(err, PublisherDoesNotExist)).To(BeTrue())
+
+		err = waitFuture(context.Background(), resolved(timeoutError, ConfirmationTimoutError))
+		Expect(err).To(Equal(ConfirmationTimoutError))
+		err = waitFuture(context.Background(), resolved(connectionCloseError, ConnectionClosed))
+		Expect(err).To(Equal(ConnectionClosed))
+	})
+})
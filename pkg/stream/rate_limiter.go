@@ -0,0 +1,256 @@
+package stream
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket on the messages and the bytes published per second.
+// The same limiter can be shared by many producers (see ProducerOptions.SetRateLimiter) and
+// by all the producers of an environment (see EnvironmentOptions.SetRateLimiter).
+// A producer with both waits for both.
+// When the tokens are not available Send and BatchSend follow the back-pressure policy of the producer:
+// BackPressureFailFast returns RateLimitExceeded, BackPressureBlockTimeout waits up to the timeout,
+// the others wait
+type RateLimiter struct {
+	mutex    *sync.Mutex
+	messages tokenBucket
+	bytes    tokenBucket
+	meter    rateMeter
+}
+
+// NewRateLimiter creates a limiter, 0 is no limit for messagesPerSecond or bytesPerSecond.
+// The burst is one second of tokens, see SetBurst
+func NewRateLimiter(messagesPerSecond float64, bytesPerSecond float64) *RateLimiter {
+	limiter := &RateLimiter{mutex: &sync.Mutex{}}
+	limiter.SetRate(messagesPerSecond, bytesPerSecond)
+	return limiter
+}
+
+// SetRate changes the limits, it can be called while the producers are sending
+func (r *RateLimiter) SetRate(messagesPerSecond float64, bytesPerSecond float64) *RateLimiter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	r.messages.setRate(messagesPerSecond, now)
+	r.bytes.setRate(bytesPerSecond, now)
+	return r
+}
+
+// SetBurst sets how many messages and bytes can be sent at once, 0 keeps the current burst
+func (r *RateLimiter) SetBurst(messages int, bytes int) *RateLimiter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if messages > 0 {
+		r.messages.setBurst(float64(messages))
+	}
+	if bytes > 0 {
+		r.bytes.setBurst(float64(bytes))
+	}
+	return r
+}
+
+// Limits returns the configured messages and bytes per second, 0 is no limit
+func (r *RateLimiter) Limits() (messagesPerSecond float64, bytesPerSecond float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.messages.rate, r.bytes.rate
+}
+
+// EffectiveRate returns the messages and bytes per second that passed the limiter in the last second
+func (r *RateLimiter) EffectiveRate() (messagesPerSecond float64, bytesPerSecond float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.meter.rate(time.Now())
+}
+
+// reserve takes the tokens and returns how long the caller must wait before sending.
+// It returns false, without taking the tokens, when the wait is longer than maxWait (negative is no max)
+func (r *RateLimiter) reserve(messages int, bytes int, maxWait time.Duration) (time.Duration, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	r.messages.advance(now)
+	r.bytes.advance(now)
+	wait := r.messages.delay(float64(messages))
+	if bytesWait := r.bytes.delay(float64(bytes)); bytesWait > wait {
+		wait = bytesWait
+	}
+	if maxWait >= 0 && wait > maxWait {
+		return 0, false
+	}
+	r.messages.take(float64(messages))
+	r.bytes.take(float64(bytes))
+	r.meter.add(now, messages, bytes)
+	return wait, true
+}
+
+// cancel gives back the tokens of a reservation not used
+func (r *RateLimiter) cancel(messages int, bytes int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.messages.take(-float64(messages))
+	r.bytes.take(-float64(bytes))
+	r.meter.add(time.Now(), -messages, -bytes)
+}
+
+// tokenBucket can go negative: a reservation bigger than the burst waits for the missing tokens
+type tokenBucket struct {
+	rate       float64 // tokens per second, 0 is no limit
+	burst      float64 // one second of tokens, unless fixedBurst
+	fixedBurst bool
+	tokens     float64
+	last       time.Time
+}
+
+func (b *tokenBucket) setRate(rate float64, now time.Time) {
+	b.advance(now)
+	if rate < 0 {
+		rate = 0
+	}
+	if b.rate == 0 {
+		// a new limit starts with a full bucket
+		b.tokens = math.Max(rate, 1)
+	}
+	b.rate = rate
+	b.last = now
+	if !b.fixedBurst {
+		b.burst = math.Max(rate, 1)
+	}
+	b.tokens = math.Min(b.tokens, b.burst)
+}
+
+func (b *tokenBucket) setBurst(burst float64) {
+	b.burst = burst
+	b.fixedBurst = true
+	b.tokens = math.Min(b.tokens, burst)
+}
+
+func (b *tokenBucket) advance(now time.Time) {
+	if b.rate == 0 {
+		return
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+func (b *tokenBucket) delay(n float64) time.Duration {
+	if b.rate == 0 || b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens -= n
+}
+
+// rateMeter counts the messages and the bytes in windows of one second
+type rateMeter struct {
+	windowStart      time.Time
+	messages         int
+	bytes            int
+	previousMessages int
+	previousBytes    int
+}
+
+func (m *rateMeter) roll(now time.Time) {
+	elapsed := now.Sub(m.windowStart)
+	if elapsed < time.Second {
+		return
+	}
+	if elapsed < 2*time.Second {
+		m.previousMessages, m.previousBytes = m.messages, m.bytes
+	} else {
+		m.previousMessages, m.previousBytes = 0, 0
+	}
+	m.messages, m.bytes = 0, 0
+	m.windowStart = now.Truncate(time.Second)
+}
+
+func (m *rateMeter) add(at time.Time, messages int, bytes int) {
+	m.roll(at)
+	m.messages += messages
+	m.bytes += bytes
+}
+
+func (m *rateMeter) rate(now time.Time) (float64, float64) {
+	m.roll(now)
+	return float64(m.previousMessages), float64(m.previousBytes)
+}
+
+// rateLimiters returns the limiter of the producer and the limiter of the environment
+func (producer *Producer) rateLimiters() []*RateLimiter {
+	limiters := make([]*RateLimiter, 0, 2)
+	if producer.options.RateLimiter != nil {
+		limiters = append(limiters, producer.options.RateLimiter)
+	}
+	if shared := producer.options.client.rateLimiter; shared != nil && shared != producer.options.RateLimiter {
+		limiters = append(limiters, shared)
+	}
+	return limiters
+}
+
+// releaseRate gives back the tokens taken by limitRate for messages that are not sent
+func (producer *Producer) releaseRate(messages int, bytes int) {
+	for _, limiter := range producer.rateLimiters() {
+		limiter.cancel(messages, bytes)
+	}
+}
+
+// limitRate waits for the tokens of the producer and of the environment limiters.
+// The wait ends when the producer is closed or stopped, the reserved tokens are given back
+func (producer *Producer) limitRate(messages int, bytes int) error {
+	return producer.limitRateWith(producer.options.BackPressure, messages, bytes)
+}
+
+func (producer *Producer) limitRateWith(policy BackPressurePolicy, messages int, bytes int) error {
+	limiters := producer.rateLimiters()
+	if len(limiters) == 0 {
+		return nil
+	}
+
+	maxWait := time.Duration(-1)
+	switch policy {
+	case BackPressureFailFast:
+		maxWait = 0
+	case BackPressureBlockTimeout:
+		maxWait = producer.options.BackPressureTimeout
+	}
+	var wait time.Duration
+	for i, limiter := range limiters {
+		limiterWait, ok := limiter.reserve(messages, bytes, maxWait)
+		if !ok {
+			for _, reserved := range limiters[:i] {
+				reserved.cancel(messages, bytes)
+			}
+			return RateLimitExceeded
+		}
+		if limiterWait > wait {
+			wait = limiterWait
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-producer.done:
+		for _, limiter := range limiters {
+			limiter.cancel(messages, bytes)
+		}
+		if producer.isStopped() {
+			return ErrShuttingDown
+		}
+		return ErrProducerClosed
+	}
+}
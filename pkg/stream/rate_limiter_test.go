@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
+)
+
+var _ = Describe("Rate limiter", func() {
+
+	It("Token bucket", func() {
+		limiter := NewRateLimiter(100, 0)
+		wait, ok := limiter.reserve(100, 1_000_000, -1)
+		Expect(ok).To(BeTrue())
+		Expect(wait).To(BeZero())
+		// the burst is one second of messages
+		_, ok = limiter.reserve(10, 0, 0)
+		Expect(ok).To(BeFalse())
+		wait, ok = limiter.reserve(10, 0, -1)
+		Expect(ok).To(BeTrue())
+		Expect(wait).To(BeNumerically("~", 100*time.Millisecond, 10*time.Millisecond))
+
+		limiter = NewRateLimiter(0, 1000).SetBurst(0, 100)
+		wait, ok = limiter.reserve(1, 500, -1)
+		Expect(ok).To(BeTrue())
+		Expect(wait).To(BeNumerically("~", 400*time.Millisecond, 10*time.Millisecond))
+		limiter.cancel(1, 500)
+		wait, _ = limiter.reserve(1, 100, -1)
+		Expect(wait).To(BeNumerically("<", 10*time.Millisecond))
+
+		messagesPerSecond, bytesPerSecond := limiter.SetRate(10, 0).Limits()
+		Expect(messagesPerSecond).To(Equal(float64(10)))
+		Expect(bytesPerSecond).To(BeZero())
+	})
+
+	It("The wait ends when the producer is closed", func() {
+		limiter := NewRateLimiter(10, 0)
+		producer := &Producer{mutex: &sync.Mutex{}, done: make(chan struct{}),
+			options: &ProducerOptions{RateLimiter: limiter, client: &Client{}}}
+		Expect(producer.limitRateWith(BackPressureBlock, 10, 0)).To(Succeed())
+
+		result := make(chan error, 1)
+		go func() {
+			result <- producer.limitRateWith(BackPressureBlock, 50, 0)
+		}()
+		Consistently(result, 100*time.Millisecond).ShouldNot(Receive())
+		producer.setStatus(closed)
+		Eventually(result, time.Second).Should(Receive(Equal(ErrProducerClosed)))
+		// the reserved tokens are given back
+		wait, _ := limiter.reserve(1, 0, -1)
+		Expect(wait).To(BeNumerically("<", 200*time.Millisecond))
+	})
+
+	It("The tokens of a message not sent are given back", func() {
+		limiter := NewRateLimiter(10, 0)
+		client := newClient("rate-limiter-test", nil, nil, nil, time.Second)
+		client.tuneState.requestedMaxFrameSize = 1_048_576
+		options := NewProducerOptions().
+			SetRateLimiter(limiter).
+			SetBackPressure(BackPressureFailFast, 0)
+		options.client = client
+		producer := &Producer{mutex: &sync.Mutex{}, mutexPending: &sync.Mutex{}, done: make(chan struct{}),
+			unConfirmedMessages: map[int64]*ConfirmationStatus{}, status: open,
+			messageSequenceCh: make(chan messageSequence, 1), options: options}
+		// the queue is full
+		producer.messageSequenceCh <- messageSequence{}
+
+		for i := 0; i < 20; i++ {
+			Expect(producer.Send(amqp.NewMessage([]byte("not sent")))).To(Equal(ProducerQueueFull))
+		}
+		Expect(producer.lenUnConfirmed()).To(BeZero())
+		_, ok := limiter.reserve(10, 0, 0)
+		Expect(ok).To(BeTrue(), "the burst is still available")
+	})
+
+	It("Producer and environment limiters", func() {
+		shared := NewRateLimiter(0, 0)
+		env, err := NewEnvironment(NewEnvironmentOptions().SetRateLimiter(shared))
+		Expect(err).NotTo(HaveOccurred())
+		streamName := uuid.New().String()
+		Expect(env.DeclareStream(streamName, nil)).NotTo(HaveOccurred())
+
+		limiter := NewRateLimiter(200, 0)
+		producer, err := env.NewProducer(streamName, NewProducerOptions().SetRateLimiter(limiter))
+		Expect(err).NotTo(HaveOccurred())
+		start := time.Now()
+		for i := 0; i < 400; i++ {
+			Expect(producer.Send(amqp.NewMessage([]byte("limited")))).NotTo(HaveOccurred())
+		}
+		// 200 of burst, then 200 messages per second
+		Expect(time.Since(start)).To(BeNumerically(">=", 900*time.Millisecond))
+		Expect(producer.BatchSend(CreateArrayMessagesForTesting(100))).NotTo(HaveOccurred())
+		Eventually(func() float64 {
+			rate, _ := shared.EffectiveRate()
+			return rate
+		}, time.Second*3).Should(BeNumerically(">", 0))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+
+		producer, err = env.NewProducer(streamName, NewProducerOptions().
+			SetRateLimiter(NewRateLimiter(1, 0)).
+			SetBackPressure(BackPressureFailFast, 0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(producer.Send(amqp.NewMessage([]byte("burst")))).NotTo(HaveOccurred())
+		Expect(producer.Send(amqp.NewMessage([]byte("limited")))).To(Equal(RateLimitExceeded))
+		Expect(producer.Close()).NotTo(HaveOccurred())
+
+		Expect(env.DeleteStream(streamName)).NotTo(HaveOccurred())
+		Expect(env.Close()).NotTo(HaveOccurred())
+	})
+})
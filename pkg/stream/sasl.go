@@ -0,0 +1,271 @@
+package stream
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SaslMechanism is the SASL mechanism used to authenticate the connections.
+// Name is the mechanism name as advertised by the broker (ex: PLAIN, SCRAM-SHA-256).
+// Start is called for each connection with the credentials of the URI and returns
+// the SaslExchange for the challenge/response flow.
+// Implement this interface to plug in a custom mechanism, see EnvironmentOptions.SetSaslMechanism
+type SaslMechanism interface {
+	Name() string
+	Start(username string, password string) (SaslExchange, error)
+}
+
+// SaslExchange is a single authentication conversation.
+// Step is called the first time with a nil challenge to get the initial response,
+// then with each challenge sent by the broker until the authentication
+// succeeds or fails.
+// When the broker accepts the authentication Step is called one last time with the
+// data sent with the success (empty when there is none), the response is ignored
+// and an error fails the authentication, ex: the SCRAM server signature doesn't match.
+type SaslExchange interface {
+	Step(challenge []byte) ([]byte, error)
+}
+
+// TokenProvider returns the token used by the OAuth 2 mechanism.
+// It is called for each new connection, so it can return a refreshed token
+type TokenProvider func() (string, error)
+
+func (s *SaslConfiguration) mechanismName() string {
+	if s.SaslMechanism != nil {
+		return s.SaslMechanism.Name()
+	}
+	return s.Mechanism
+}
+
+func (s *SaslConfiguration) saslMechanism() (SaslMechanism, error) {
+	if s.SaslMechanism != nil {
+		if s.SaslMechanism.Name() == "" {
+			return nil, fmt.Errorf("SaslMechanism name can't be empty")
+		}
+		return s.SaslMechanism, nil
+	}
+	switch s.Mechanism {
+	case SaslConfigurationPlain, SaslConfigurationExternal:
+		return &plainSaslMechanism{name: s.Mechanism}, nil
+	case SaslConfigurationScramSha256:
+		return NewScramSha256SaslMechanism(), nil
+	}
+	return nil, fmt.Errorf("SaslConfiguration mechanism must be PLAIN, EXTERNAL or SCRAM-SHA-256, use SetSaslMechanism for custom mechanisms")
+}
+
+// plainSaslMechanism sends the credentials as: NUL user NUL password
+type plainSaslMechanism struct {
+	name string
+}
+
+func (p *plainSaslMechanism) Name() string {
+	return p.name
+}
+
+func (p *plainSaslMechanism) Start(username string, password string) (SaslExchange, error) {
+	return &singleStepSaslExchange{response: []byte(unicodeNull + username + unicodeNull + password)}, nil
+}
+
+type singleStepSaslExchange struct {
+	response []byte
+	done     bool
+}
+
+func (s *singleStepSaslExchange) Step(challenge []byte) ([]byte, error) {
+	if !s.done {
+		s.done = true
+		return s.response, nil
+	}
+	// the success of the broker: a single step mechanism doesn't expect any data
+	if len(challenge) > 0 {
+		return nil, fmt.Errorf("unexpected SASL challenge")
+	}
+	return nil, nil
+}
+
+// saslConversation runs the exchange until the broker accepts or refuses the authentication.
+// send returns the data sent by the broker and errSaslChallenge while the conversation goes on.
+// The data sent with the success is passed to the exchange, so the mechanism can verify the broker
+func saslConversation(exchange SaslExchange, send func(response []byte) ([]byte, error)) error {
+	var challenge []byte
+	for {
+		response, err := exchange.Step(challenge)
+		if err != nil {
+			return err
+		}
+		challenge, err = send(response)
+		if err == nil {
+			_, err = exchange.Step(challenge)
+			return err
+		}
+		if err != errSaslChallenge {
+			return err
+		}
+	}
+}
+
+// NewOAuth2SaslMechanism returns a mechanism for the RabbitMQ OAuth 2 plugin.
+// The token returned by the provider is sent as password with the PLAIN mechanism,
+// the username of the URI is ignored by the broker.
+func NewOAuth2SaslMechanism(provider TokenProvider) SaslMechanism {
+	return &oAuth2SaslMechanism{provider: provider}
+}
+
+type oAuth2SaslMechanism struct {
+	provider TokenProvider
+}
+
+func (o *oAuth2SaslMechanism) Name() string {
+	return SaslConfigurationPlain
+}
+
+func (o *oAuth2SaslMechanism) Start(username string, _ string) (SaslExchange, error) {
+	token, err := o.provider()
+	if err != nil {
+		return nil, fmt.Errorf("can't get the OAuth 2 token: %w", err)
+	}
+	return &singleStepSaslExchange{response: []byte(unicodeNull + username + unicodeNull + token)}, nil
+}
+
+// NewScramSha256SaslMechanism returns the SCRAM-SHA-256 mechanism (RFC 7677).
+// The authentication fails if the broker doesn't send the server-final message
+// or if the broker signature doesn't match.
+func NewScramSha256SaslMechanism() SaslMechanism {
+	return &scramSha256SaslMechanism{}
+}
+
+type scramSha256SaslMechanism struct {
+}
+
+func (s *scramSha256SaslMechanism) Name() string {
+	return SaslConfigurationScramSha256
+}
+
+func (s *scramSha256SaslMechanism) Start(username string, password string) (SaslExchange, error) {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &scramExchange{
+		username:    username,
+		password:    password,
+		clientNonce: base64.StdEncoding.EncodeToString(nonce),
+	}, nil
+}
+
+type scramExchange struct {
+	username        string
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+	step            int
+}
+
+func (s *scramExchange) Step(challenge []byte) ([]byte, error) {
+	s.step++
+	switch s.step {
+	case 1:
+		s.clientFirstBare = "n=" + scramEscape(s.username) + ",r=" + s.clientNonce
+		return []byte("n,," + s.clientFirstBare), nil
+	case 2:
+		return s.clientFinal(string(challenge))
+	case 3:
+		return s.verifyServerFinal(string(challenge))
+	case 4:
+		// the server-final message was sent as a challenge, the success has no data
+		if len(challenge) == 0 {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected SCRAM challenge")
+}
+
+func (s *scramExchange) clientFinal(serverFirst string) ([]byte, error) {
+	attributes := scramAttributes(serverFirst)
+	nonce := attributes["r"]
+	if !strings.HasPrefix(nonce, s.clientNonce) {
+		return nil, fmt.Errorf("SCRAM server nonce doesn't match the client nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attributes["s"])
+	if err != nil {
+		return nil, fmt.Errorf("SCRAM invalid salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(attributes["i"])
+	if err != nil || iterations < 1 {
+		return nil, fmt.Errorf("SCRAM invalid iteration count: %s", attributes["i"])
+	}
+
+	saltedPassword := pbkdf2Sha256([]byte(s.password), salt, iterations)
+	clientKey := hmacSha256(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	clientFinalWithoutProof := "c=biws,r=" + nonce
+	authMessage := []byte(s.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof)
+
+	clientSignature := hmacSha256(storedKey[:], authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	s.serverSignature = hmacSha256(hmacSha256(saltedPassword, []byte("Server Key")), authMessage)
+	return []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (s *scramExchange) verifyServerFinal(serverFinal string) ([]byte, error) {
+	if serverFinal == "" {
+		return nil, fmt.Errorf("SCRAM server-final message missing, the broker signature can't be verified")
+	}
+	attributes := scramAttributes(serverFinal)
+	if e, ok := attributes["e"]; ok {
+		return nil, fmt.Errorf("SCRAM authentication error: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attributes["v"])
+	if err != nil || !hmac.Equal(signature, s.serverSignature) {
+		return nil, fmt.Errorf("SCRAM invalid server signature")
+	}
+	return []byte{}, nil
+}
+
+func scramEscape(value string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(value)
+}
+
+func scramAttributes(message string) map[string]string {
+	attributes := make(map[string]string)
+	for _, attribute := range strings.Split(message, ",") {
+		if len(attribute) > 1 && attribute[1] == '=' {
+			attributes[attribute[:1]] = attribute[2:]
+		}
+	}
+	return attributes
+}
+
+func hmacSha256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// pbkdf2Sha256 is the PBKDF2 function with a key length of one SHA-256 block, as required by SCRAM
+func pbkdf2Sha256(password []byte, salt []byte, iterations int) []byte {
+	var firstBlock bytes.Buffer
+	firstBlock.Write(salt)
+	_ = binary.Write(&firstBlock, binary.BigEndian, uint32(1))
+	u := hmacSha256(password, firstBlock.Bytes())
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < iterations; i++ {
+		u = hmacSha256(password, u)
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
@@ -0,0 +1,188 @@
+package stream
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type testSaslMechanism struct {
+	name string
+}
+
+func (t *testSaslMechanism) Name() string {
+	return t.name
+}
+
+func (t *testSaslMechanism) Start(username string, password string) (SaslExchange, error) {
+	return &singleStepSaslExchange{response: []byte(username)}, nil
+}
+
+var _ = Describe("Sasl", func() {
+
+	It("PLAIN and EXTERNAL responses", func() {
+		for _, name := range []string{SaslConfigurationPlain, SaslConfigurationExternal} {
+			mechanism, err := (&SaslConfiguration{Mechanism: name}).saslMechanism()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mechanism.Name()).To(Equal(name))
+			exchange, err := mechanism.Start("guest", "secret")
+			Expect(err).NotTo(HaveOccurred())
+			response, err := exchange.Step(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(response)).To(Equal("\u0000guest\u0000secret"))
+			_, err = exchange.Step([]byte("unexpected"))
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("Mechanism validation", func() {
+		_, err := (&SaslConfiguration{Mechanism: "IS_NOT_VALID"}).saslMechanism()
+		Expect(err).To(HaveOccurred())
+
+		_, err = (&SaslConfiguration{Mechanism: SaslConfigurationPlain,
+			SaslMechanism: &testSaslMechanism{}}).saslMechanism()
+		Expect(err).To(HaveOccurred())
+
+		mechanism, err := (&SaslConfiguration{Mechanism: "IS_NOT_VALID",
+			SaslMechanism: &testSaslMechanism{name: "CUSTOM"}}).saslMechanism()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mechanism.Name()).To(Equal("CUSTOM"))
+
+		mechanism, err = (&SaslConfiguration{Mechanism: SaslConfigurationScramSha256}).saslMechanism()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mechanism.Name()).To(Equal(SaslConfigurationScramSha256))
+
+		_, err = NewEnvironment(NewEnvironmentOptions().SetSaslMechanism(&testSaslMechanism{}))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("OAuth 2 token", func() {
+		token := "token-1"
+		mechanism := NewOAuth2SaslMechanism(func() (string, error) {
+			return token, nil
+		})
+		Expect(mechanism.Name()).To(Equal(SaslConfigurationPlain))
+		exchange, err := mechanism.Start("", "ignored")
+		Expect(err).NotTo(HaveOccurred())
+		response, err := exchange.Step(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(response)).To(Equal("\u0000\u0000token-1"))
+
+		// the token is read for each new exchange
+		token = "token-2"
+		exchange, err = mechanism.Start("", "ignored")
+		Expect(err).NotTo(HaveOccurred())
+		response, err = exchange.Step(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(response)).To(Equal("\u0000\u0000token-2"))
+
+		_, err = NewOAuth2SaslMechanism(func() (string, error) {
+			return "", errors.New("expired")
+		}).Start("", "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("SCRAM-SHA-256 RFC 7677 example", func() {
+		exchange := &scramExchange{username: "user", password: "pencil",
+			clientNonce: "rOprNGfwEbeRWgbNEkqO"}
+
+		clientFirst, err := exchange.Step(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(clientFirst)).To(Equal("n,,n=user,r=rOprNGfwEbeRWgbNEkqO"))
+
+		clientFinal, err := exchange.Step([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+			"s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(clientFinal)).To(Equal("c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+			"p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="))
+
+		last, err := exchange.Step([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last).To(BeEmpty())
+	})
+
+	It("SCRAM-SHA-256 validates the server messages", func() {
+		exchange := &scramExchange{username: "user", password: "pencil", clientNonce: "abc"}
+		_, err := exchange.Step(nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = exchange.Step([]byte("r=xyz,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+		Expect(err).To(HaveOccurred())
+
+		exchange = &scramExchange{username: "user", password: "pencil", clientNonce: "abc"}
+		_, _ = exchange.Step(nil)
+		_, err = exchange.Step([]byte("r=abcdef,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = exchange.Step([]byte("v=bm90LXRoZS1zaWduYXR1cmU="))
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("SCRAM-SHA-256 conversation with the RFC 7677 example", func() {
+		const serverFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+		const serverFinal = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+
+		// broker replies with the server-first challenge, then with the success and the final data
+		broker := func(final string) func(response []byte) ([]byte, error) {
+			step := 0
+			return func(response []byte) ([]byte, error) {
+				step++
+				if step == 1 {
+					Expect(string(response)).To(Equal("n,,n=user,r=rOprNGfwEbeRWgbNEkqO"))
+					return []byte(serverFirst), errSaslChallenge
+				}
+				Expect(string(response)).To(HaveSuffix("p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="))
+				return []byte(final), nil
+			}
+		}
+		newExchange := func() SaslExchange {
+			return &scramExchange{username: "user", password: "pencil", clientNonce: "rOprNGfwEbeRWgbNEkqO"}
+		}
+
+		It("verifies the server signature sent with the success", func() {
+			Expect(saslConversation(newExchange(), broker(serverFinal))).To(Succeed())
+		})
+
+		It("fails when the server signature doesn't match", func() {
+			err := saslConversation(newExchange(), broker("v=bm90LXRoZS1zaWduYXR1cmU="))
+			Expect(err).To(MatchError(ContainSubstring("invalid server signature")))
+		})
+
+		It("fails when the server-final message is missing", func() {
+			err := saslConversation(newExchange(), broker(""))
+			Expect(err).To(MatchError(ContainSubstring("server-final message missing")))
+		})
+
+		It("accepts the server-final message sent as a challenge", func() {
+			step := 0
+			Expect(saslConversation(newExchange(), func(response []byte) ([]byte, error) {
+				step++
+				switch step {
+				case 1:
+					return []byte(serverFirst), errSaslChallenge
+				case 2:
+					return []byte(serverFinal), errSaslChallenge
+				}
+				Expect(response).To(BeEmpty())
+				return nil, nil
+			})).To(Succeed())
+		})
+	})
+
+	It("Single step conversation", func() {
+		exchange := &singleStepSaslExchange{response: []byte("response")}
+		Expect(saslConversation(exchange, func(response []byte) ([]byte, error) {
+			Expect(string(response)).To(Equal("response"))
+			return nil, nil
+		})).To(Succeed())
+
+		refused := errors.New("authentication failure")
+		exchange = &singleStepSaslExchange{response: []byte("response")}
+		Expect(saslConversation(exchange, func(_ []byte) ([]byte, error) {
+			return nil, refused
+		})).To(MatchError(refused))
+	})
+
+	It("SCRAM username escape", func() {
+		Expect(scramEscape("a=b,c")).To(Equal("a=3Db=2Cc"))
+	})
+})
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
-	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
 	"hash/crc32"
 	"io"
 	"time"
@@ -21,9 +20,9 @@ type ReaderProtocol struct {
 	PublishingIdCount uint64
 }
 
-func logErrorCommand(error error, details string) {
+func (c *Client) logErrorCommand(error error, details string) {
 	if error != nil {
-		logs.LogError("Error handling command response: %s - details: %s", error, details)
+		c.logger.Error("Error handling command response", "details", details, "error", error)
 	}
 }
 
@@ -33,8 +32,11 @@ func (c *Client) handleResponse() {
 		readerProtocol := &ReaderProtocol{}
 		frameLen, err := readUInt(buffer)
 		if err != nil {
-			logs.LogDebug("Read connection failed: %s", err)
-			_ = c.Close()
+			c.logger.Debug("Read connection failed", "error", err)
+			if c.socket.isOpen() {
+				c.notifyConnectionEvent(ConnectionEventLost, "read connection failed", err)
+			}
+			_ = c.closeWithReason("read connection failed")
 			break
 		}
 		c.setLastHeartBeat(time.Now())
@@ -52,14 +54,19 @@ func (c *Client) handleResponse() {
 			{
 				c.handleSaslHandshakeResponse(readerProtocol, buffer)
 			}
+		case commandSaslAuthenticate:
+			{
+				c.handleSaslAuthenticateResponse(readerProtocol, buffer)
+			}
 		case commandTune:
 			{
 				c.handleTune(buffer)
 			}
 		case commandDeclarePublisher,
 			CommandDeletePublisher, commandDeleteStream,
-			commandCreateStream, commandSaslAuthenticate, commandSubscribe,
-			CommandUnsubscribe, commandCreateSuperStream, commandDeleteSuperStream:
+			commandCreateStream, commandSubscribe,
+			CommandUnsubscribe, commandCreateSuperStream, commandDeleteSuperStream,
+			commandUpdateSecret:
 			{
 				c.handleGenericResponse(readerProtocol, buffer)
 			}
@@ -135,7 +142,7 @@ func (c *Client) handleResponse() {
 			}
 		default:
 			{
-				logs.LogWarn("Command not implemented %d buff:%d \n", readerProtocol.CommandID, buffer.Buffered())
+				c.logger.Warn("Command not implemented", "command_id", readerProtocol.CommandID, "buffered", buffer.Buffered())
 				break
 			}
 		}
@@ -163,6 +170,21 @@ func (c *Client) handleSaslHandshakeResponse(streamingRes *ReaderProtocol, r *bu
 	return mechanisms
 }
 
+func (c *Client) handleSaslAuthenticateResponse(readProtocol *ReaderProtocol, r *bufio.Reader) {
+	readProtocol.CorrelationId, _ = readUInt(r)
+	readProtocol.ResponseCode = uShortExtractResponseCode(readUShort(r))
+	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
+	c.logErrorCommand(err, "handleSaslAuthenticateResponse")
+	// the opaque data is always there with a challenge,
+	// with a success it is there only when the frame is longer than the header
+	if readProtocol.ResponseCode == responseCodeSaslChallenge ||
+		(readProtocol.ResponseCode == responseCodeOk && readProtocol.FrameLen > 2+2+4+2) {
+		challengeSize, _ := readUInt(r)
+		res.data <- readUint8Array(r, challengeSize)
+	}
+	res.code <- Code{id: readProtocol.ResponseCode}
+}
+
 func (c *Client) handlePeerProperties(readProtocol *ReaderProtocol, r *bufio.Reader) {
 	readProtocol.CorrelationId, _ = readUInt(r)
 	readProtocol.ResponseCode = uShortExtractResponseCode(readUShort(r))
@@ -175,7 +197,7 @@ func (c *Client) handlePeerProperties(readProtocol *ReaderProtocol, r *bufio.Rea
 		serverProperties[key] = value
 	}
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "handlePeerProperties")
+	c.logErrorCommand(err, "handlePeerProperties")
 	res.code <- Code{id: readProtocol.ResponseCode}
 	res.data <- serverProperties
 
@@ -197,7 +219,7 @@ func (c *Client) handleTune(r *bufio.Reader) interface{} {
 	writeUInt(b, maxFrameSize)
 	writeUInt(b, heartbeat)
 	res, err := c.coordinator.GetResponseByName("tune")
-	logErrorCommand(err, "handleTune")
+	c.logErrorCommand(err, "handleTune")
 	res.data <- b.Bytes()
 	return b.Bytes()
 
@@ -207,7 +229,7 @@ func (c *Client) handleGenericResponse(readProtocol *ReaderProtocol, r *bufio.Re
 	readProtocol.CorrelationId, _ = readUInt(r)
 	readProtocol.ResponseCode = uShortExtractResponseCode(readUShort(r))
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "handleGenericResponse")
+	c.logErrorCommand(err, "handleGenericResponse")
 	res.code <- Code{id: readProtocol.ResponseCode}
 }
 
@@ -234,7 +256,7 @@ func (c *Client) commandOpen(readProtocol *ReaderProtocol, r *bufio.Reader) {
 	}
 
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "commandOpen")
+	c.logErrorCommand(err, "commandOpen")
 	res.code <- Code{id: readProtocol.ResponseCode}
 	res.data <- clientProperties
 
@@ -248,26 +270,35 @@ func (c *Client) handleConfirm(readProtocol *ReaderProtocol, r *bufio.Reader) in
 	//var _publishingId int64
 	producer, err := c.coordinator.GetProducerById(readProtocol.PublishID)
 	if err != nil {
-		logs.LogWarn("can't find the producer during confirmation: %s", err)
+		c.logger.Warn("Can't find the producer during confirmation", "error", err)
 		return nil
 	}
 	var unConfirmed []*ConfirmationStatus
+	var outboxConfirmed []int64
 	for publishingIdCount != 0 {
 		seq := readInt64(r)
+		if producer.options.Outbox != nil {
+			// the message can be in the outbox also after the confirmation timeout
+			outboxConfirmed = append(outboxConfirmed, seq)
+		}
 
 		m := producer.getUnConfirmed(seq)
 		if m != nil {
 			m.confirmed = true
+			m.resolveFuture()
 			unConfirmed = append(unConfirmed, m)
 			producer.removeUnConfirmed(m.publishingId)
+			c.metrics.MessageConfirmed(producer, time.Since(m.inserted))
 
 			// in case of sub-batch entry the client receives only
 			// one publishingId (or sequence)
 			// so the other messages are confirmed using the linkedTo
 			for _, message := range m.linkedTo {
 				message.confirmed = true
+				message.resolveFuture()
 				unConfirmed = append(unConfirmed, message)
 				producer.removeUnConfirmed(message.publishingId)
+				c.metrics.MessageConfirmed(producer, time.Since(message.inserted))
 			}
 		}
 		//} else {
@@ -275,6 +306,8 @@ func (c *Client) handleConfirm(readProtocol *ReaderProtocol, r *bufio.Reader) in
 		//}
 		publishingIdCount--
 	}
+	producer.outboxRemove(outboxConfirmed)
+	producer.reportQueueDepth()
 
 	producer.mutex.Lock()
 	if producer.publishConfirm != nil {
@@ -291,7 +324,7 @@ func (c *Client) queryPublisherSequenceFrameHandler(readProtocol *ReaderProtocol
 	readProtocol.ResponseCode = uShortExtractResponseCode(readUShort(r))
 	sequence := readInt64(r)
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "queryPublisherSequenceFrameHandler")
+	c.logErrorCommand(err, "queryPublisherSequenceFrameHandler")
 	res.code <- Code{id: readProtocol.ResponseCode}
 	res.data <- sequence
 }
@@ -300,7 +333,7 @@ func (c *Client) handleDeliver(r *bufio.Reader) {
 	subscriptionId := readByte(r)
 	consumer, err := c.coordinator.GetConsumerById(subscriptionId)
 	if err != nil {
-		logs.LogError("Handle Deliver consumer not found %s", err)
+		c.logger.Error("Handle Deliver consumer not found", "subscription_id", subscriptionId, "error", err)
 		return
 
 	}
@@ -308,7 +341,7 @@ func (c *Client) handleDeliver(r *bufio.Reader) {
 	_ = readByte(r)
 	chunkType := readByte(r)
 	if chunkType != 0 {
-		logs.LogWarn("Invalid chunkType: %d ", chunkType)
+		c.logger.Warn("Invalid chunkType", "chunk_type", chunkType)
 	}
 
 	numEntries := readUShort(r)
@@ -322,6 +355,8 @@ func (c *Client) handleDeliver(r *bufio.Reader) {
 	_, _ = readUInt(r)
 
 	c.credit(subscriptionId, 1)
+	c.metrics.ChunkDelivered(consumer, int(numRecords), int(dataLength))
+	c.metrics.CreditGranted(consumer, 1)
 
 	var offsetLimit int64 = -1
 
@@ -353,14 +388,14 @@ func (c *Client) handleDeliver(r *bufio.Reader) {
 	chunk.numEntries = numEntries
 	var bytesBuffer = make([]byte, int(dataLength))
 	_, err = io.ReadFull(r, bytesBuffer)
-	logErrorCommand(err, "handleDeliver")
+	c.logErrorCommand(err, "handleDeliver")
 
 	/// headers ---> payload -> messages
 
 	if consumer.options.CRCCheck {
 		checkSum := crc32.ChecksumIEEE(bytesBuffer)
 		if crc != checkSum {
-			logs.LogError("Error during the checkSum, expected %d, checksum %d", crc, checkSum)
+			c.logger.Error("Error during the checkSum", "expected", crc, "checksum", checkSum)
 			panic("Error during CRC")
 		} /// ???
 	}
@@ -373,10 +408,10 @@ func (c *Client) handleDeliver(r *bufio.Reader) {
 
 		if err != nil {
 			if err == io.EOF {
-				logs.LogDebug("EOF reading entryType %s ", err)
+				c.logger.Debug("EOF reading entryType", "error", err)
 				return
 			} else {
-				logs.LogWarn("error reading entryType %s ", err)
+				c.logger.Warn("Error reading entryType", "error", err)
 			}
 		}
 		if (entryType & 0x80) == 0 {
@@ -395,7 +430,7 @@ func (c *Client) handleDeliver(r *bufio.Reader) {
 			dataSize, _ := readUInt(dataReader)
 			numRecords -= uint32(numRecordsInBatch)
 			compression := (entryType & 0x70) >> 4 //compression
-			uncompressedReader := compressByValue(compression).UnCompress(dataReader,
+			uncompressedReader := compressByValue(compression, c.logger).UnCompress(dataReader,
 				dataSize,
 				uncompressedDataSize)
 
@@ -415,8 +450,8 @@ func (c *Client) handleDeliver(r *bufio.Reader) {
 	if consumer.getStatus() == open {
 		consumer.response.chunkForConsumer <- chunk
 	} else {
-		logs.LogDebug("The consumer %s for the stream %s is closed during the chunk dispatching. "+
-			"Messages won't dispatched", consumer.GetName(), consumer.GetStreamName())
+		c.logger.Debug("The consumer is closed during the chunk dispatching. Messages won't dispatched",
+			"consumer", consumer.GetName(), "stream", consumer.GetStreamName())
 	}
 
 }
@@ -429,7 +464,7 @@ func (c *Client) decodeMessage(r *bufio.Reader, filter bool, offset int64, offse
 	} else {
 		msg := &amqp.Message{}
 		err := msg.UnmarshalBinary(arrayMessage)
-		logErrorCommand(err, "error unmarshal messages")
+		c.logErrorCommand(err, "error unmarshal messages")
 		batchConsumingMessages = append(batchConsumingMessages,
 			&offsetMessage{offset: offset, message: msg})
 	}
@@ -442,12 +477,12 @@ func (c *Client) creditNotificationFrameHandler(readProtocol *ReaderProtocol,
 	subscriptionId := readByte(r)
 	consumer, err := c.coordinator.GetConsumerById(subscriptionId)
 	if err != nil {
-		logs.LogWarn("received a credit for an unknown subscriptionId: %d", subscriptionId)
+		c.logger.Warn("Received a credit for an unknown subscriptionId", "subscription_id", subscriptionId)
 		return
 	}
 
 	if consumer != nil && consumer.getStatus() == closed {
-		logs.LogDebug("received a credit for a closed consumer %d", subscriptionId)
+		c.logger.Debug("Received a credit for a closed consumer", "subscription_id", subscriptionId)
 		return
 	}
 
@@ -458,7 +493,7 @@ func (c *Client) queryOffsetFrameHandler(readProtocol *ReaderProtocol,
 	c.handleGenericResponse(readProtocol, r)
 	offset := readInt64(r)
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "queryOffsetFrameHandler")
+	c.logErrorCommand(err, "queryOffsetFrameHandler")
 	res.data <- offset
 }
 
@@ -474,20 +509,32 @@ func (c *Client) handlePublishError(buffer *bufio.Reader) {
 		code = readUShort(buffer)
 		producer, err := c.coordinator.GetProducerById(publisherId)
 		if err != nil {
-			logs.LogWarn("producer id %d not found, publish error :%s", publisherId, lookErrorCode(code))
+			c.logger.Warn("Producer not found, publish error", "producer_id", publisherId, "error", lookErrorCode(code))
 			producer = &Producer{unConfirmedMessages: map[int64]*ConfirmationStatus{}}
 		} else {
 			unConfirmedMessage := producer.getUnConfirmed(publishingId)
 
 			producer.mutex.Lock()
 
-			if producer.publishConfirm != nil && unConfirmedMessage != nil {
+			if unConfirmedMessage != nil {
 				unConfirmedMessage.errorCode = code
 				unConfirmedMessage.err = lookErrorCode(code)
+				unConfirmedMessage.resolveFuture()
+				// the messages of the same sub-entry batch have the same error
+				for _, message := range unConfirmedMessage.linkedTo {
+					message.errorCode = code
+					message.err = unConfirmedMessage.err
+					message.resolveFuture()
+				}
+			}
+			if producer.publishConfirm != nil && unConfirmedMessage != nil {
 				producer.publishConfirm <- []*ConfirmationStatus{unConfirmedMessage}
 			}
 			producer.mutex.Unlock()
 			producer.removeUnConfirmed(publishingId)
+			// a rejected message is not replayed
+			producer.outboxRemove([]int64{publishingId})
+			c.metrics.PublishError(producer, code)
 		}
 		publishingErrorCount--
 	}
@@ -498,7 +545,7 @@ func (c *Client) metadataUpdateFrameHandler(buffer *bufio.Reader) {
 	code := readUShort(buffer)
 	if code == responseCodeStreamNotAvailable {
 		stream := readString(buffer)
-		logs.LogDebug("stream %s is no longer available", stream)
+		c.logger.Debug("Stream is no longer available", "stream", stream)
 		c.mutex.Lock()
 		c.metadataListener <- metaDataUpdateEvent{
 			StreamName: stream,
@@ -508,7 +555,7 @@ func (c *Client) metadataUpdateFrameHandler(buffer *bufio.Reader) {
 
 	} else {
 		//TODO handle the error, see the java code
-		logs.LogWarn("unsupported metadata update code %d", code)
+		c.logger.Warn("Unsupported metadata update code", "code", code)
 	}
 }
 
@@ -526,7 +573,7 @@ func (c *Client) streamStatusFrameHandler(readProtocol *ReaderProtocol,
 		streamStatus[key] = value
 	}
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "streamStatusFrameHandler")
+	c.logErrorCommand(err, "streamStatusFrameHandler")
 	res.code <- Code{id: readProtocol.ResponseCode}
 	res.data <- streamStatus
 
@@ -563,7 +610,7 @@ func (c *Client) metadataFrameHandler(readProtocol *ReaderProtocol,
 	}
 
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "metadataFrameHandler")
+	c.logErrorCommand(err, "metadataFrameHandler")
 
 	res.code <- Code{id: readProtocol.ResponseCode}
 	res.data <- streamsMetadata
@@ -574,8 +621,9 @@ func (c *Client) closeFrameHandler(readProtocol *ReaderProtocol,
 	readProtocol.CorrelationId, _ = readUInt(r)
 	readProtocol.ResponseCode = uShortExtractResponseCode(readUShort(r))
 	closeReason := readString(r)
-	logs.LogDebug("Received close from server, reason: %s %s %d", lookErrorCode(readProtocol.ResponseCode),
-		closeReason, readProtocol.ResponseCode)
+	c.logger.Debug("Received close from server", "reason", closeReason,
+		"code", readProtocol.ResponseCode, "error", lookErrorCode(readProtocol.ResponseCode))
+	c.notifyConnectionEvent(ConnectionEventCloseReceived, closeReason, lookErrorCode(readProtocol.ResponseCode))
 
 	length := 2 + 2 + 4 + 2
 	var b = bytes.NewBuffer(make([]byte, 0, length))
@@ -584,7 +632,7 @@ func (c *Client) closeFrameHandler(readProtocol *ReaderProtocol,
 	writeUShort(b, responseCodeOk)
 
 	err := c.socket.writeAndFlush(b.Bytes())
-	logErrorCommand(err, "Socket write buffer closeFrameHandler")
+	c.logErrorCommand(err, "Socket write buffer closeFrameHandler")
 
 }
 
@@ -594,9 +642,9 @@ func (c *Client) handleConsumerUpdate(readProtocol *ReaderProtocol, r *bufio.Rea
 	isActive := readByte(r)
 	consumer, err := c.coordinator.GetConsumerById(subscriptionId)
 
-	logErrorCommand(err, "handleConsumerUpdate")
+	c.logErrorCommand(err, "handleConsumerUpdate")
 	if consumer == nil {
-		logs.LogWarn("consumer not found %d. The consumer maybe removed before the update", subscriptionId)
+		c.logger.Warn("Consumer not found. The consumer maybe removed before the update", "subscription_id", subscriptionId)
 		return
 	}
 	consumer.setPromotedAsActive(isActive == 1)
@@ -609,7 +657,7 @@ func (c *Client) handleConsumerUpdate(readProtocol *ReaderProtocol, r *bufio.Rea
 	}
 
 	err = consumer.writeConsumeUpdateOffsetToSocket(readProtocol.CorrelationId, responseOff)
-	logErrorCommand(err, "handleConsumerUpdate writeConsumeUpdateOffsetToSocket")
+	c.logErrorCommand(err, "handleConsumerUpdate writeConsumeUpdateOffsetToSocket")
 }
 
 func (c *Client) handleQueryPartitions(readProtocol *ReaderProtocol, r *bufio.Reader) {
@@ -622,7 +670,7 @@ func (c *Client) handleQueryPartitions(readProtocol *ReaderProtocol, r *bufio.Re
 		partitions = append(partitions, partition)
 	}
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "handleQueryPartitions")
+	c.logErrorCommand(err, "handleQueryPartitions")
 	res.code <- Code{id: readProtocol.ResponseCode}
 	res.data <- partitions
 }
@@ -639,7 +687,7 @@ func (c *Client) handleQueryRoute(readProtocol *ReaderProtocol, r *bufio.Reader)
 	}
 
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "handleQueryRoute")
+	c.logErrorCommand(err, "handleQueryRoute")
 	res.code <- Code{id: readProtocol.ResponseCode}
 	res.data <- routes
 }
@@ -656,12 +704,13 @@ func (c *Client) handleExchangeVersionResponse(readProtocol *ReaderProtocol, r *
 		commands = append(commands, newCommandVersionResponse(minVersion, maxVersion, commandKey))
 	}
 	res, err := c.coordinator.GetResponseById(readProtocol.CorrelationId)
-	logErrorCommand(err, "handleExchangeVersionResponse")
+	c.logErrorCommand(err, "handleExchangeVersionResponse")
 	res.code <- Code{id: readProtocol.ResponseCode}
 	res.data <- commands
 }
 
 func (c *Client) handleHeartbeat() {
-	logs.LogDebug("Heart beat received at %s", time.Now())
+	c.logger.Debug("Heart beat received", "time", time.Now())
 	c.setLastHeartBeat(time.Now())
+	c.metrics.HeartbeatReceived(c.getConnectionName())
 }
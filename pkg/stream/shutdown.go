@@ -0,0 +1,165 @@
+package stream
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const shutdownPollInterval = 50 * time.Millisecond
+
+// ShutdownReport contains what Environment.Shutdown could not drain before the deadline.
+// An empty report means that all the messages are confirmed and all the offsets are stored
+type ShutdownReport struct {
+	Producers []ProducerShutdownReport
+	Consumers []ConsumerShutdownReport
+}
+
+// ProducerShutdownReport is a producer with messages not confirmed at the end of the shutdown
+type ProducerShutdownReport struct {
+	ID          uint8
+	Stream      string
+	Name        string
+	Pending     int // messages not sent to the broker
+	UnConfirmed int // messages sent and not confirmed
+}
+
+// ConsumerShutdownReport is a consumer whose final offset is not stored
+type ConsumerShutdownReport struct {
+	ID     uint8
+	Stream string
+	Name   string
+	Offset int64 // the offset that should have been stored
+	Err    error
+}
+
+// Drained returns true if all the messages are confirmed and all the offsets are stored
+func (r *ShutdownReport) Drained() bool {
+	return len(r.Producers) == 0 && len(r.Consumers) == 0
+}
+
+// Shutdown closes the environment gracefully:
+//   - the producers stop accepting new messages, Send and BatchSend return ErrShuttingDown
+//   - the queued messages are sent and the outstanding confirmations are awaited
+//   - the named consumers with automatic offset tracking store the offset of the last message dispatched
+//   - the connections are closed
+//
+// The producers and consumers are drained until the context is done, then the environment
+// is closed anyway. The report contains what could not be drained, the error is ctx.Err()
+// when the deadline is reached.
+// Consumers with manual offset tracking must store the offset before calling Shutdown
+func (env *Environment) Shutdown(ctx context.Context) (*ShutdownReport, error) {
+	atomic.StoreInt32(&env.shuttingDown, 1)
+	report := &ShutdownReport{}
+
+	producers := env.producersList()
+	for _, producer := range producers {
+		producer.stopSending()
+	}
+	for _, producer := range producers {
+		if !producer.drain(ctx) {
+			report.Producers = append(report.Producers, ProducerShutdownReport{
+				ID:          producer.GetID(),
+				Stream:      producer.GetStreamName(),
+				Name:        producer.GetName(),
+				Pending:     len(producer.messageSequenceCh) + producer.lenPendingMessages(),
+				UnConfirmed: producer.lenUnConfirmed(),
+			})
+		}
+	}
+
+	for _, consumer := range env.consumersList() {
+		if consumer.getStatus() != open || !consumer.options.autocommit || consumer.GetName() == "" {
+			continue
+		}
+		offset := consumer.GetOffset()
+		if offset < 0 {
+			// nothing consumed
+			continue
+		}
+		if err := consumer.storeFinalOffset(ctx, offset); err != nil {
+			report.Consumers = append(report.Consumers, ConsumerShutdownReport{
+				ID:     consumer.ID,
+				Stream: consumer.GetStreamName(),
+				Name:   consumer.GetName(),
+				Offset: offset,
+				Err:    err,
+			})
+		}
+	}
+
+	err := ctx.Err()
+	_ = env.Close()
+	return report, err
+}
+
+func (env *Environment) isShuttingDown() bool {
+	return atomic.LoadInt32(&env.shuttingDown) == 1
+}
+
+func (env *Environment) producersList() []*Producer {
+	var result []*Producer
+	for _, coordinator := range env.producers.getCoordinators() {
+		for _, client := range coordinator.clients() {
+			result = append(result, client.coordinator.producersList()...)
+		}
+	}
+	return result
+}
+
+func (env *Environment) consumersList() []*Consumer {
+	var result []*Consumer
+	for _, coordinator := range env.consumers.getCoordinators() {
+		for _, client := range coordinator.clients() {
+			result = append(result, client.coordinator.consumersList()...)
+		}
+	}
+	return result
+}
+
+func (producer *Producer) stopSending() {
+	atomic.StoreInt32(&producer.stopped, 1)
+	producer.signalDone()
+}
+
+func (producer *Producer) isStopped() bool {
+	return atomic.LoadInt32(&producer.stopped) == 1
+}
+
+// drain waits until the queued messages are sent and confirmed.
+// It returns false if the context is done before
+func (producer *Producer) drain(ctx context.Context) bool {
+	for producer.getStatus() == open {
+		if len(producer.messageSequenceCh) == 0 && producer.lenPendingMessages() == 0 &&
+			producer.lenUnConfirmed() == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(shutdownPollInterval):
+		}
+	}
+	return producer.lenUnConfirmed() == 0
+}
+
+// storeFinalOffset stores the offset and waits until the broker returns it
+func (consumer *Consumer) storeFinalOffset(ctx context.Context, offset int64) error {
+	if err := consumer.internalStoreOffset(); err != nil {
+		return err
+	}
+	for {
+		stored, err := consumer.options.client.queryOffsetCtx(ctx, consumer.GetName(), consumer.GetStreamName())
+		if err == nil && stored >= offset {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(shutdownPollInterval):
+		}
+	}
+}
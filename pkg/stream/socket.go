@@ -2,8 +2,7 @@ package stream
 
 import (
 	"bufio"
-	"fmt"
-	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
+	"context"
 	"net"
 	"sync"
 )
@@ -27,23 +26,23 @@ func (sck *socket) isOpen() bool {
 	defer sck.mutex.Unlock()
 	return sck.closed == 1
 }
-func (sck *socket) shutdown(err error) {
+
+// shutdown closes the connection, it returns the error of the connection close
+func (sck *socket) shutdown(_ error) error {
 	if !sck.isOpen() {
-		return
+		return nil
 	}
 	sck.mutex.Lock()
 	sck.closed = 0
 	sck.mutex.Unlock()
 
+	var errClose error
 	sck.destructor.Do(func() {
 		sck.mutex.Lock()
 		defer sck.mutex.Unlock()
-		err := sck.connection.Close()
-		if err != nil {
-			logs.LogWarn("error during close socket: %s", err)
-		}
+		errClose = sck.connection.Close()
 	})
-
+	return errClose
 }
 
 func (sck *socket) writeAndFlush(buffer []byte) error {
@@ -65,9 +64,17 @@ func (c *Client) handleWrite(buffer []byte, response *Response) responseError {
 	return c.handleWriteWithResponse(buffer, response, true)
 }
 
+func (c *Client) handleWriteCtx(ctx context.Context, buffer []byte, response *Response) responseError {
+	return c.handleWriteWithResponseCtx(ctx, buffer, response, true)
+}
+
 func (c *Client) handleWriteWithResponse(buffer []byte, response *Response, removeResponse bool) responseError {
+	return c.handleWriteWithResponseCtx(context.Background(), buffer, response, removeResponse)
+}
+
+func (c *Client) handleWriteWithResponseCtx(ctx context.Context, buffer []byte, response *Response, removeResponse bool) responseError {
 	result := c.socket.writeAndFlush(buffer)
-	resultCode := waitCodeWithTimeOut(response, c.socketCallTimeout)
+	resultCode := waitCodeWithContext(ctx, response, c.socketCallTimeout, c.logger)
 	/// we need to remove the response before evaluate the
 	// buffer errSocket
 	if removeResponse {
@@ -76,7 +83,7 @@ func (c *Client) handleWriteWithResponse(buffer []byte, response *Response, remo
 
 	if result != nil {
 		// we just log
-		fmt.Printf("Error handleWrite %s", result)
+		c.logger.Warn("Error handleWrite", "operation", response.commandDescription, "error", result)
 	}
 
 	return resultCode
@@ -0,0 +1,177 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Socks5Auth is the username/password authentication of the SOCKS5 proxy (RFC 1929)
+type Socks5Auth struct {
+	User     string
+	Password string
+}
+
+const (
+	socks5Version          = byte(5)
+	socks5AuthNone         = byte(0)
+	socks5AuthPassword     = byte(2)
+	socks5AuthNoAcceptable = byte(0xff)
+	socks5CommandConnect   = byte(1)
+	socks5AddressIPv4      = byte(1)
+	socks5AddressDomain    = byte(3)
+	socks5AddressIPv6      = byte(4)
+)
+
+var socks5Replies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// NewSocks5Dialer returns a Dialer that connects to the brokers through the SOCKS5 proxy (RFC 1928),
+// proxyAddress is host:port. The host name of the broker is resolved by the proxy.
+// auth is nil when the proxy doesn't require authentication
+func NewSocks5Dialer(proxyAddress string, auth *Socks5Auth) Dialer {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, proxyAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		// the handshake is bound to the context
+		done := make(chan struct{})
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			select {
+			case <-ctx.Done():
+				_ = conn.SetDeadline(time.Unix(1, 0))
+			case <-done:
+			}
+		}()
+		err = socks5Handshake(conn, addr, auth)
+		close(done)
+		<-exited
+		if err == nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			_ = conn.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("socks5 proxy %s: %w", proxyAddress, err)
+		}
+		_ = conn.SetDeadline(time.Time{})
+		return conn, nil
+	}
+}
+
+func socks5Handshake(conn net.Conn, addr string, auth *Socks5Auth) error {
+	host, portValue, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portValue)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port %s", portValue)
+	}
+
+	method := socks5AuthNone
+	if auth != nil {
+		method = socks5AuthPassword
+	}
+	if _, err = conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("unexpected version %d", reply[0])
+	}
+	if reply[1] == socks5AuthNoAcceptable || reply[1] != method {
+		return fmt.Errorf("authentication method not accepted")
+	}
+
+	if method == socks5AuthPassword {
+		if len(auth.User) > 255 || len(auth.Password) > 255 {
+			return fmt.Errorf("user and password must be shorter than 256 bytes")
+		}
+		request := []byte{1, byte(len(auth.User))}
+		request = append(request, auth.User...)
+		request = append(request, byte(len(auth.Password)))
+		request = append(request, auth.Password...)
+		if _, err = conn.Write(request); err != nil {
+			return err
+		}
+		if _, err = io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("authentication failed")
+		}
+	}
+
+	request := []byte{socks5Version, socks5CommandConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			request = append(request, socks5AddressIPv4)
+			request = append(request, ip4...)
+		} else {
+			request = append(request, socks5AddressIPv6)
+			request = append(request, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long: %s", host)
+		}
+		request = append(request, socks5AddressDomain, byte(len(host)))
+		request = append(request, host...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err = conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err = io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		message, ok := socks5Replies[header[1]]
+		if !ok {
+			message = fmt.Sprintf("reply code %d", header[1])
+		}
+		return fmt.Errorf("can't connect to %s: %s", addr, message)
+	}
+
+	// the bound address is not used
+	var boundLength int
+	switch header[3] {
+	case socks5AddressIPv4:
+		boundLength = net.IPv4len
+	case socks5AddressIPv6:
+		boundLength = net.IPv6len
+	case socks5AddressDomain:
+		length := make([]byte, 1)
+		if _, err = io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		boundLength = int(length[0])
+	default:
+		return fmt.Errorf("unexpected address type %d", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, boundLength+2))
+	return err
+}
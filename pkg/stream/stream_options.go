@@ -2,13 +2,32 @@ package stream
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
+const initialClusterSize = "initial-cluster-size"
+const streamFilterSizeBytes = "stream-filter-size-bytes"
+
+const (
+	minFilterSizeBytes = 16
+	maxFilterSizeBytes = 255
+)
+
 type StreamOptions struct {
 	MaxAge              time.Duration
 	MaxLengthBytes      *ByteCapacity
 	MaxSegmentSizeBytes *ByteCapacity
+	// LeaderLocator is LeaderLocatorBalanced, LeaderLocatorClientLocal, LeaderLocatorLeastLeaders
+	// or LeaderLocatorRandom, the default is LeaderLocatorLeastLeaders
+	LeaderLocator string
+	// InitialClusterSize is the number of nodes (leader + replicas), 0 uses the broker default
+	InitialClusterSize int
+	// FilterSizeBytes is the size of the Bloom filter used by the filtering feature, between 16 and 255
+	FilterSizeBytes int
+	// Arguments are extra x-arguments sent as they are. They can't override the options above
+	Arguments map[string]string
 }
 
 func (s *StreamOptions) SetMaxAge(maxAge time.Duration) *StreamOptions {
@@ -26,8 +45,60 @@ func (s *StreamOptions) SetMaxSegmentSizeBytes(segmentSize *ByteCapacity) *Strea
 	return s
 }
 
+func (s *StreamOptions) SetLeaderLocator(leaderLocator string) *StreamOptions {
+	s.LeaderLocator = leaderLocator
+	return s
+}
+
+func (s *StreamOptions) SetBalancedLeaderLocator() *StreamOptions {
+	s.LeaderLocator = LeaderLocatorBalanced
+	return s
+}
+
+func (s *StreamOptions) SetClientLocalLocator() *StreamOptions {
+	s.LeaderLocator = LeaderLocatorClientLocal
+	return s
+}
+
+func (s *StreamOptions) SetInitialClusterSize(size int) *StreamOptions {
+	s.InitialClusterSize = size
+	return s
+}
+
+func (s *StreamOptions) SetFilterSizeBytes(size int) *StreamOptions {
+	s.FilterSizeBytes = size
+	return s
+}
+
+// SetArgument adds an x-argument not covered by the options, for example a new broker argument
+func (s *StreamOptions) SetArgument(key string, value string) *StreamOptions {
+	if s.Arguments == nil {
+		s.Arguments = map[string]string{}
+	}
+	s.Arguments[key] = value
+	return s
+}
+
+// Validate checks the options before declaring the stream
+func (s StreamOptions) Validate() error {
+	_, err := s.buildParameters()
+	return err
+}
+
 func (s StreamOptions) buildParameters() (map[string]string, error) {
-	res := map[string]string{"queue-leader-locator": "least-leaders"}
+	res, err := s.buildArguments()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := res[queueLeaderLocator]; !ok {
+		res[queueLeaderLocator] = LeaderLocatorLeastLeaders
+	}
+	return res, nil
+}
+
+// buildArguments returns only the arguments set, it is used by DeclareStream and by the super stream partitions
+func (s StreamOptions) buildArguments() (map[string]string, error) {
+	res := map[string]string{}
 
 	if s.MaxLengthBytes != nil {
 		if s.MaxLengthBytes.error != nil {
@@ -35,7 +106,7 @@ func (s StreamOptions) buildParameters() (map[string]string, error) {
 		}
 
 		if s.MaxLengthBytes.bytes > 0 {
-			res["max-length-bytes"] = fmt.Sprintf("%d", s.MaxLengthBytes.bytes)
+			res[maxLengthBytes] = fmt.Sprintf("%d", s.MaxLengthBytes.bytes)
 		}
 	}
 
@@ -45,12 +116,54 @@ func (s StreamOptions) buildParameters() (map[string]string, error) {
 		}
 
 		if s.MaxSegmentSizeBytes.bytes > 0 {
-			res["stream-max-segment-size-bytes"] = fmt.Sprintf("%d", s.MaxSegmentSizeBytes.bytes)
+			res[streamMaxSegmentSizeBytes] = fmt.Sprintf("%d", s.MaxSegmentSizeBytes.bytes)
 		}
 	}
 
 	if s.MaxAge > 0 {
-		res["max-age"] = fmt.Sprintf("%.0fs", s.MaxAge.Seconds())
+		res[maxAge] = fmt.Sprintf("%.0fs", s.MaxAge.Seconds())
+	}
+
+	switch s.LeaderLocator {
+	case "":
+	case LeaderLocatorBalanced, LeaderLocatorClientLocal, LeaderLocatorLeastLeaders, LeaderLocatorRandom:
+		res[queueLeaderLocator] = s.LeaderLocator
+	default:
+		return nil, fmt.Errorf("LeaderLocator must be %s, %s, %s or %s, value: %s",
+			LeaderLocatorBalanced, LeaderLocatorClientLocal, LeaderLocatorLeastLeaders, LeaderLocatorRandom, s.LeaderLocator)
+	}
+
+	if s.InitialClusterSize < 0 {
+		return nil, fmt.Errorf("InitialClusterSize can't be negative, value: %d", s.InitialClusterSize)
+	}
+	if s.InitialClusterSize > 0 {
+		res[initialClusterSize] = fmt.Sprintf("%d", s.InitialClusterSize)
+	}
+
+	if s.FilterSizeBytes != 0 {
+		if s.FilterSizeBytes < minFilterSizeBytes || s.FilterSizeBytes > maxFilterSizeBytes {
+			return nil, fmt.Errorf("FilterSizeBytes values must be between %d and %d",
+				minFilterSizeBytes, maxFilterSizeBytes)
+		}
+		res[streamFilterSizeBytes] = fmt.Sprintf("%d", s.FilterSizeBytes)
+	}
+
+	// sorted to have always the same error
+	keys := make([]string, 0, len(s.Arguments))
+	for key := range s.Arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := s.Arguments[key]
+		if strings.TrimSpace(key) == "" || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("argument key and value can't be empty, key: %q value: %q", key, value)
+		}
+		switch key {
+		case maxAge, maxLengthBytes, streamMaxSegmentSizeBytes, queueLeaderLocator, initialClusterSize, streamFilterSizeBytes:
+			return nil, fmt.Errorf("argument %s must be set with the StreamOptions field, not with Arguments", key)
+		}
+		res[key] = value
 	}
 	return res, nil
 }
@@ -2,6 +2,13 @@ package stream
 
 import "fmt"
 
+// The keys of the stream statistics returned by the broker, see StreamStats.Raw
+const (
+	StreamStatsFirstChunkId     = "first_chunk_id"
+	StreamStatsLastChunkId      = "last_chunk_id"
+	StreamStatsCommittedChunkId = "committed_chunk_id"
+)
+
 type StreamStats struct {
 	stats      map[string]int64
 	streamName string
@@ -12,23 +19,65 @@ func newStreamStats(stats map[string]int64, streamName string) *StreamStats {
 
 }
 
+func (s *StreamStats) StreamName() string {
+	return s.streamName
+}
+
+// Raw returns a copy of all the statistics returned by the broker, including the keys
+// not known by this client version
+func (s *StreamStats) Raw() map[string]int64 {
+	raw := make(map[string]int64, len(s.stats))
+	for key, value := range s.stats {
+		raw[key] = value
+	}
+	return raw
+}
+
+// Get returns the value of a statistic, false if the broker doesn't return the key
+func (s *StreamStats) Get(key string) (int64, bool) {
+	value, ok := s.stats[key]
+	return value, ok
+}
+
+// FirstChunkId is the ID (offset) of the first chunk of the stream, the same as FirstOffset
+func (s *StreamStats) FirstChunkId() (int64, error) {
+	return s.chunkId(StreamStatsFirstChunkId)
+}
+
+// LastChunkId is the ID (offset) of the last chunk of the stream, committed or not. See CommittedChunkId
+func (s *StreamStats) LastChunkId() (int64, error) {
+	return s.chunkId(StreamStatsLastChunkId)
+}
+
+// chunkId returns an error when the key is missing or -1 (no chunk yet)
+func (s *StreamStats) chunkId(key string) (int64, error) {
+	value, ok := s.stats[key]
+	if !ok {
+		return -1, fmt.Errorf("%s not returned by the broker for %s", key, s.streamName)
+	}
+	if value == -1 {
+		return -1, fmt.Errorf("%s not found for %s", key, s.streamName)
+	}
+	return value, nil
+}
+
 // FirstOffset - The first offset in the stream.
 // return first offset in the stream /
 // Error if there is no first offset yet
 func (s *StreamStats) FirstOffset() (int64, error) {
-	if s.stats["first_chunk_id"] == -1 {
+	if s.stats[StreamStatsFirstChunkId] == -1 {
 		return -1, fmt.Errorf("FirstOffset not found for %s", s.streamName)
 	}
-	return s.stats["first_chunk_id"], nil
+	return s.stats[StreamStatsFirstChunkId], nil
 }
 
 // Deprecated: The method name may be misleading.
 // It does not indicate the last offset of the stream. It indicates the last uncommited chunk id. This information is not necessary. The user should use CommittedChunkId().
 func (s *StreamStats) LastOffset() (int64, error) {
-	if s.stats["last_chunk_id"] == -1 {
+	if s.stats[StreamStatsLastChunkId] == -1 {
 		return -1, fmt.Errorf("LastOffset not found for %s", s.streamName)
 	}
-	return s.stats["last_chunk_id"], nil
+	return s.stats[StreamStatsLastChunkId], nil
 }
 
 // CommittedChunkId - The ID (offset) of the committed chunk (block of messages) in the stream.
@@ -43,8 +92,8 @@ func (s *StreamStats) LastOffset() (int64, error) {
 //	return committed offset in this stream
 //	Error if there is no committed chunk yet
 func (s *StreamStats) CommittedChunkId() (int64, error) {
-	if s.stats["committed_chunk_id"] == -1 {
+	if s.stats[StreamStatsCommittedChunkId] == -1 {
 		return -1, fmt.Errorf("CommittedChunkId not found for %s", s.streamName)
 	}
-	return s.stats["committed_chunk_id"], nil
+	return s.stats[StreamStatsCommittedChunkId], nil
 }
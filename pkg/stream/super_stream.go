@@ -33,7 +33,7 @@ const streamMaxSegmentSizeBytes = "stream-max-segment-size-bytes"
 type SuperStreamOptions interface {
 	getPartitions(prefix string) []string
 	getBindingKeys() []string
-	getArgs() map[string]string
+	getArgs() (map[string]string, error)
 }
 
 type PartitionsOptions struct {
@@ -42,13 +42,14 @@ type PartitionsOptions struct {
 	MaxLengthBytes      *ByteCapacity
 	MaxSegmentSizeBytes *ByteCapacity
 	LeaderLocator       string
-	args                map[string]string
+	InitialClusterSize  int
+	FilterSizeBytes     int
+	Arguments           map[string]string // see StreamOptions.Arguments
 }
 
 func NewPartitionsOptions(partitions int) *PartitionsOptions {
 	return &PartitionsOptions{
 		Partitions: partitions,
-		args:       make(map[string]string),
 	}
 }
 
@@ -94,20 +95,34 @@ func (t *PartitionsOptions) getBindingKeys() []string {
 	return bindingKeys
 }
 
-func (t *PartitionsOptions) getArgs() map[string]string {
-	if t.MaxAge > 0 {
-		t.args[maxAge] = fmt.Sprintf("%ds", int(t.MaxAge.Seconds()))
-	}
-	if t.MaxLengthBytes != nil {
-		t.args[maxLengthBytes] = fmt.Sprintf("%d", t.MaxLengthBytes.bytes)
-	}
-	if t.MaxSegmentSizeBytes != nil {
-		t.args[streamMaxSegmentSizeBytes] = fmt.Sprintf("%d", t.MaxSegmentSizeBytes.bytes)
-	}
-	if t.LeaderLocator != "" {
-		t.args[queueLeaderLocator] = t.LeaderLocator
+func (t *PartitionsOptions) SetInitialClusterSize(size int) *PartitionsOptions {
+	t.InitialClusterSize = size
+	return t
+}
+
+func (t *PartitionsOptions) SetFilterSizeBytes(size int) *PartitionsOptions {
+	t.FilterSizeBytes = size
+	return t
+}
+
+func (t *PartitionsOptions) SetArgument(key string, value string) *PartitionsOptions {
+	if t.Arguments == nil {
+		t.Arguments = map[string]string{}
 	}
-	return t.args
+	t.Arguments[key] = value
+	return t
+}
+
+func (t *PartitionsOptions) getArgs() (map[string]string, error) {
+	return StreamOptions{
+		MaxAge:              t.MaxAge,
+		MaxLengthBytes:      t.MaxLengthBytes,
+		MaxSegmentSizeBytes: t.MaxSegmentSizeBytes,
+		LeaderLocator:       t.LeaderLocator,
+		InitialClusterSize:  t.InitialClusterSize,
+		FilterSizeBytes:     t.FilterSizeBytes,
+		Arguments:           t.Arguments,
+	}.buildArguments()
 }
 
 type BindingsOptions struct {
@@ -116,13 +131,14 @@ type BindingsOptions struct {
 	MaxLengthBytes      *ByteCapacity
 	MaxSegmentSizeBytes *ByteCapacity
 	LeaderLocator       string
-	args                map[string]string
+	InitialClusterSize  int
+	FilterSizeBytes     int
+	Arguments           map[string]string // see StreamOptions.Arguments
 }
 
 func NewBindingsOptions(bindings []string) *BindingsOptions {
 	return &BindingsOptions{
 		Bindings: bindings,
-		args:     make(map[string]string),
 	}
 }
 
@@ -163,18 +179,32 @@ func (t *BindingsOptions) getBindingKeys() []string {
 	return t.Bindings
 }
 
-func (t *BindingsOptions) getArgs() map[string]string {
-	if t.MaxAge > 0 {
-		t.args[maxAge] = fmt.Sprintf("%ds", int(t.MaxAge.Seconds()))
-	}
-	if t.MaxLengthBytes != nil {
-		t.args[maxLengthBytes] = fmt.Sprintf("%d", t.MaxLengthBytes.bytes)
-	}
-	if t.MaxSegmentSizeBytes != nil {
-		t.args[streamMaxSegmentSizeBytes] = fmt.Sprintf("%d", t.MaxSegmentSizeBytes.bytes)
-	}
-	if t.LeaderLocator != "" {
-		t.args[queueLeaderLocator] = t.LeaderLocator
+func (t *BindingsOptions) SetInitialClusterSize(size int) *BindingsOptions {
+	t.InitialClusterSize = size
+	return t
+}
+
+func (t *BindingsOptions) SetFilterSizeBytes(size int) *BindingsOptions {
+	t.FilterSizeBytes = size
+	return t
+}
+
+func (t *BindingsOptions) SetArgument(key string, value string) *BindingsOptions {
+	if t.Arguments == nil {
+		t.Arguments = map[string]string{}
 	}
-	return t.args
+	t.Arguments[key] = value
+	return t
+}
+
+func (t *BindingsOptions) getArgs() (map[string]string, error) {
+	return StreamOptions{
+		MaxAge:              t.MaxAge,
+		MaxLengthBytes:      t.MaxLengthBytes,
+		MaxSegmentSizeBytes: t.MaxSegmentSizeBytes,
+		LeaderLocator:       t.LeaderLocator,
+		InitialClusterSize:  t.InitialClusterSize,
+		FilterSizeBytes:     t.FilterSizeBytes,
+		Arguments:           t.Arguments,
+	}.buildArguments()
 }
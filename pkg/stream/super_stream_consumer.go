@@ -1,9 +1,9 @@
 package stream
 
 import (
+	"context"
 	"fmt"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
-	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
 	"sync"
 	"time"
 )
@@ -108,7 +108,7 @@ func newSuperStreamConsumer(env *Environment, superStream string, MessagesHandle
 		return nil, fmt.Errorf("super Stream Name can't be empty")
 	}
 
-	logs.LogDebug("Creating a SuperStreamConsumer for: %s", superStream)
+	env.GetLogger().Debug("[SuperStreamConsumer] Creating a SuperStreamConsumer", "super_stream", superStream)
 
 	return &SuperStreamConsumer{
 		env:                        env,
@@ -119,13 +119,17 @@ func newSuperStreamConsumer(env *Environment, superStream string, MessagesHandle
 }
 
 func (s *SuperStreamConsumer) init() error {
-	partitions, err := s.env.QueryPartitions(s.SuperStream)
+	return s.initCtx(context.Background())
+}
+
+func (s *SuperStreamConsumer) initCtx(ctx context.Context) error {
+	partitions, err := s.env.QueryPartitionsCtx(ctx, s.SuperStream)
 	s.partitions = partitions
 	if err != nil {
 		return err
 	}
 	for _, p := range partitions {
-		err = s.ConnectPartition(p, s.SuperStreamConsumerOptions.Offset)
+		err = s.connectPartitionCtx(ctx, p, s.SuperStreamConsumerOptions.Offset)
 		if err != nil {
 			return err
 		}
@@ -149,7 +153,11 @@ func (s *SuperStreamConsumer) getConsumers() []*Consumer {
 }
 
 func (s *SuperStreamConsumer) ConnectPartition(partition string, offset OffsetSpecification) error {
-	logs.LogDebug("[SuperStreamConsumer] ConnectPartition for partition: %s", partition)
+	return s.connectPartitionCtx(context.Background(), partition, offset)
+}
+
+func (s *SuperStreamConsumer) connectPartitionCtx(ctx context.Context, partition string, offset OffsetSpecification) error {
+	s.env.GetLogger().Debug("[SuperStreamConsumer] ConnectPartition", "partition", partition)
 	s.mutex.Lock()
 	found := false
 	for _, p := range s.partitions {
@@ -203,10 +211,10 @@ func (s *SuperStreamConsumer) ConnectPartition(partition string, offset OffsetSp
 		if s.MessagesHandler != nil {
 			s.MessagesHandler(consumerContext, message)
 		} else {
-			logs.LogWarn("[SuperStreamConsumer] No handler set for partition: %s", consumerContext.Consumer.GetStreamName())
+			s.env.GetLogger().Warn("[SuperStreamConsumer] No handler set", "partition", consumerContext.Consumer.GetStreamName())
 		}
 	}
-	consumer, err := s.env.NewConsumer(partition, messagesHandler,
+	consumer, err := s.env.NewConsumerCtx(ctx, partition, messagesHandler,
 		options.SetConsumerName(s.SuperStreamConsumerOptions.ConsumerName))
 	if err != nil {
 		return err
@@ -217,7 +225,7 @@ func (s *SuperStreamConsumer) ConnectPartition(partition string, offset OffsetSp
 	s.mutex.Unlock()
 
 	go func(gpartion string, _closedEvent <-chan Event) {
-		logs.LogDebug("[SuperStreamConsumer] chSuperStreamPartitionClose started for partition: %s", gpartion)
+		s.env.GetLogger().Debug("[SuperStreamConsumer] chSuperStreamPartitionClose started", "partition", gpartion)
 		// one shot event
 		event := <-_closedEvent
 		s.mutex.Lock()
@@ -237,14 +245,14 @@ func (s *SuperStreamConsumer) ConnectPartition(partition string, offset OffsetSp
 			}
 			s.mutex.Unlock()
 		}
-		logs.LogDebug("[SuperStreamConsumer] chSuperStreamPartitionClose for partition: %s", gpartion)
+		s.env.GetLogger().Debug("[SuperStreamConsumer] chSuperStreamPartitionClose", "partition", gpartion)
 	}(partition, closedEvent)
 
 	return nil
 }
 
 func (s *SuperStreamConsumer) Close() error {
-	logs.LogDebug("[SuperStreamConsumer] Closing SuperStreamConsumer for: %s", s.SuperStream)
+	s.env.GetLogger().Debug("[SuperStreamConsumer] Closing SuperStreamConsumer", "super_stream", s.SuperStream)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	for len(s.activeConsumers) > 0 {
@@ -265,6 +273,6 @@ func (s *SuperStreamConsumer) Close() error {
 		s.mutex.Unlock()
 	}()
 
-	logs.LogDebug("[SuperStreamConsumer] Closed SuperStreamConsumer for: %s", s.SuperStream)
+	s.env.GetLogger().Debug("[SuperStreamConsumer] Closed SuperStreamConsumer", "super_stream", s.SuperStream)
 	return nil
 }
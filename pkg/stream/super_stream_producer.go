@@ -1,8 +1,8 @@
 package stream
 
 import (
+	"context"
 	"fmt"
-	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/message"
 	"github.com/spaolacci/murmur3"
 	"sync"
@@ -197,7 +197,7 @@ func newSuperStreamProducer(env *Environment, superStream string, superStreamPro
 		return nil, fmt.Errorf("super Stream Name can't be empty")
 	}
 
-	logs.LogDebug("Creating a SuperStreamProducer for: %s", superStream)
+	env.GetLogger().Debug("[SuperStreamProducer] Creating a SuperStreamProducer", "super_stream", superStream)
 	return &SuperStreamProducer{
 		activeProducers:            make([]*Producer, 0),
 		env:                        env,
@@ -208,16 +208,20 @@ func newSuperStreamProducer(env *Environment, superStream string, superStreamPro
 }
 
 func (s *SuperStreamProducer) init() error {
+	return s.initCtx(context.Background())
+}
+
+func (s *SuperStreamProducer) initCtx(ctx context.Context) error {
 	// set the routing strategy parameters
 	s.SuperStreamProducerOptions.RoutingStrategy.SetRouteParameters(s.SuperStream, s.env.QueryRoute)
 
-	partitions, err := s.env.QueryPartitions(s.SuperStream)
+	partitions, err := s.env.QueryPartitionsCtx(ctx, s.SuperStream)
 	s.partitions = partitions
 	if err != nil {
 		return err
 	}
 	for _, p := range partitions {
-		err = s.ConnectPartition(p)
+		err = s.connectPartitionCtx(ctx, p)
 		if err != nil {
 			return err
 		}
@@ -231,7 +235,11 @@ func (s *SuperStreamProducer) init() error {
 // with the ConnectPartition the user can re-connect a partition to the SuperStreamProducer
 // that should be used only in case of disconnection
 func (s *SuperStreamProducer) ConnectPartition(partition string) error {
-	logs.LogDebug("[SuperStreamProducer] ConnectPartition for partition: %s", partition)
+	return s.connectPartitionCtx(context.Background(), partition)
+}
+
+func (s *SuperStreamProducer) connectPartitionCtx(ctx context.Context, partition string) error {
+	s.env.GetLogger().Debug("[SuperStreamProducer] ConnectPartition", "partition", partition)
 
 	s.mutex.Lock()
 	found := false
@@ -259,7 +267,7 @@ func (s *SuperStreamProducer) ConnectPartition(partition string) error {
 	}
 	options = options.SetFilter(s.SuperStreamProducerOptions.Filter)
 
-	producer, err := s.env.NewProducer(partition, options)
+	producer, err := s.env.NewProducerCtx(ctx, partition, options)
 	if err != nil {
 		return err
 	}
@@ -270,7 +278,7 @@ func (s *SuperStreamProducer) ConnectPartition(partition string) error {
 	s.mutex.Unlock()
 
 	go func(gpartion string, _closedEvent <-chan Event) {
-		logs.LogDebug("[SuperStreamProducer] chSuperStreamPartitionClose started for partition: %s", gpartion)
+		s.env.GetLogger().Debug("[SuperStreamProducer] chSuperStreamPartitionClose started", "partition", gpartion)
 		event := <-_closedEvent
 
 		s.mutex.Lock()
@@ -290,11 +298,11 @@ func (s *SuperStreamProducer) ConnectPartition(partition string) error {
 			}
 			s.mutex.Unlock()
 		}
-		logs.LogDebug("[SuperStreamProducer] chSuperStreamPartitionClose for partition: %s", gpartion)
+		s.env.GetLogger().Debug("[SuperStreamProducer] chSuperStreamPartitionClose", "partition", gpartion)
 	}(partition, closedEvent)
 
 	go func(gpartion string, ch <-chan []*ConfirmationStatus) {
-		logs.LogDebug("[SuperStreamProducer] chNotifyPublishConfirmation started - partition: %s", gpartion)
+		s.env.GetLogger().Debug("[SuperStreamProducer] chNotifyPublishConfirmation started", "partition", gpartion)
 		for confirmed := range ch {
 			if s.chNotifyPublishConfirmation != nil {
 				s.chNotifyPublishConfirmation <- PartitionPublishConfirm{
@@ -304,7 +312,7 @@ func (s *SuperStreamProducer) ConnectPartition(partition string) error {
 
 			}
 		}
-		logs.LogDebug("[SuperStreamProducer] chNotifyPublishConfirmation closed - partition: %s", gpartion)
+		s.env.GetLogger().Debug("[SuperStreamProducer] chNotifyPublishConfirmation closed", "partition", gpartion)
 	}(partition, chSingleStreamPublishConfirmation)
 
 	return nil
@@ -386,7 +394,7 @@ func (s *SuperStreamProducer) Send(message message.StreamMessage) error {
 }
 
 func (s *SuperStreamProducer) Close() error {
-	logs.LogDebug("[SuperStreamProducer] Closing a SuperStreamProducer for: %s", s.SuperStream)
+	s.env.GetLogger().Debug("[SuperStreamProducer] Closing a SuperStreamProducer", "super_stream", s.SuperStream)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	for len(s.activeProducers) > 0 {
@@ -411,6 +419,6 @@ func (s *SuperStreamProducer) Close() error {
 		}
 		s.mutex.Unlock()
 	}()
-	logs.LogDebug("[SuperStreamProducer] Closed SuperStreamProducer for: %s", s.SuperStream)
+	s.env.GetLogger().Debug("[SuperStreamProducer] Closed SuperStreamProducer", "super_stream", s.SuperStream)
 	return nil
 }
@@ -28,8 +28,8 @@ func (t *testSuperStreamOption) getBindingKeys() []string {
 	return t.bindingKey
 }
 
-func (t *testSuperStreamOption) getArgs() map[string]string {
-	return t.args
+func (t *testSuperStreamOption) getArgs() (map[string]string, error) {
+	return t.args, nil
 }
 
 var _ = Describe("Super Stream Client", Label("super-stream"), func() {
@@ -176,6 +176,22 @@ var _ = Describe("Super Stream Client", Label("super-stream"), func() {
 		Expect(err).To(Equal(StreamDoesNotExist))
 	})
 
+	It("Create Super stream with initial cluster size and filter size", Label("super-stream"), func() {
+		options := NewPartitionsOptions(2).
+			SetInitialClusterSize(1).
+			SetFilterSizeBytes(64).
+			SetArgument("x-custom", "value")
+		args, err := options.getArgs()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(args).To(HaveKeyWithValue("initial-cluster-size", "1"))
+		Expect(args).To(HaveKeyWithValue("stream-filter-size-bytes", "64"))
+		Expect(args).To(HaveKeyWithValue("x-custom", "value"))
+
+		Expect(testEnvironment.DeclareSuperStream("go-super-stream-cluster-size",
+			NewPartitionsOptions(2).SetInitialClusterSize(1).SetFilterSizeBytes(64))).NotTo(HaveOccurred())
+		Expect(testEnvironment.DeleteSuperStream("go-super-stream-cluster-size")).NotTo(HaveOccurred())
+	})
+
 	It("Create Super stream with 3 keys and other parameters", Label("super-stream"), func() {
 		err := testEnvironment.DeclareSuperStream("go-countries",
 			NewBindingsOptions([]string{"italy", "spain", "france"}).
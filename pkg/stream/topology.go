@@ -0,0 +1,108 @@
+package stream
+
+import "sort"
+
+// TopologySnapshot is a read-only copy of the connections of the environment,
+// useful to debug how the producers and consumers are spread across the connections.
+// See Environment.Topology
+type TopologySnapshot struct {
+	Producers []CoordinatorSnapshot
+	Consumers []CoordinatorSnapshot
+}
+
+// CoordinatorSnapshot contains the clients (connections) opened to the same broker
+type CoordinatorSnapshot struct {
+	Broker  string // host:port of the broker
+	Clients []ClientSnapshot
+}
+
+type ClientSnapshot struct {
+	ConnectionName string
+	Open           bool
+	Producers      []ProducerSnapshot
+	Consumers      []ConsumerSnapshot
+}
+
+type ProducerSnapshot struct {
+	ID          uint8
+	Stream      string
+	Name        string
+	Status      string // open or closed
+	Pending     int    // messages waiting to be sent
+	UnConfirmed int    // messages sent and waiting for the confirmation
+}
+
+type ConsumerSnapshot struct {
+	ID     uint8
+	Stream string
+	Name   string
+	Status string // open or closed
+	Offset int64  // the offset of the last message dispatched
+}
+
+// Topology returns a snapshot of the producer and consumer connections of the environment,
+// grouped by broker. The snapshot is a copy and it is safe to use it while the environment changes
+func (env *Environment) Topology() TopologySnapshot {
+	return TopologySnapshot{
+		Producers: coordinatorsSnapshot(env.producers.getCoordinators()),
+		Consumers: coordinatorsSnapshot(env.consumers.getCoordinators()),
+	}
+}
+
+func statusName(status int) string {
+	if status == open {
+		return "open"
+	}
+	return "closed"
+}
+
+func coordinatorsSnapshot(coordinators map[string]*environmentCoordinator) []CoordinatorSnapshot {
+	result := make([]CoordinatorSnapshot, 0, len(coordinators))
+	for broker, coordinator := range coordinators {
+		coordinatorSnapshot := CoordinatorSnapshot{Broker: broker}
+		for _, client := range coordinator.clients() {
+			coordinatorSnapshot.Clients = append(coordinatorSnapshot.Clients, client.snapshot())
+		}
+		sort.Slice(coordinatorSnapshot.Clients, func(i, j int) bool {
+			return coordinatorSnapshot.Clients[i].ConnectionName < coordinatorSnapshot.Clients[j].ConnectionName
+		})
+		result = append(result, coordinatorSnapshot)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Broker < result[j].Broker
+	})
+	return result
+}
+
+func (c *Client) snapshot() ClientSnapshot {
+	result := ClientSnapshot{
+		ConnectionName: c.getConnectionName(),
+		Open:           c.socket.isOpen(),
+	}
+	for _, producer := range c.coordinator.producersList() {
+		result.Producers = append(result.Producers, ProducerSnapshot{
+			ID:          producer.GetID(),
+			Stream:      producer.GetStreamName(),
+			Name:        producer.GetName(),
+			Status:      statusName(producer.getStatus()),
+			Pending:     producer.lenPendingMessages(),
+			UnConfirmed: producer.lenUnConfirmed(),
+		})
+	}
+	sort.Slice(result.Producers, func(i, j int) bool {
+		return result.Producers[i].ID < result.Producers[j].ID
+	})
+	for _, consumer := range c.coordinator.consumersList() {
+		result.Consumers = append(result.Consumers, ConsumerSnapshot{
+			ID:     consumer.ID,
+			Stream: consumer.GetStreamName(),
+			Name:   consumer.GetName(),
+			Status: statusName(consumer.getStatus()),
+			Offset: consumer.GetOffset(),
+		})
+	}
+	sort.Slice(result.Consumers, func(i, j int) bool {
+		return result.Consumers[i].ID < result.Consumers[j].ID
+	})
+	return result
+}
@@ -0,0 +1,415 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// TopologySpec is the description of the streams and super streams of an application,
+// see ParseTopology and Environment.ApplyTopology. It is not the TopologySnapshot of the
+// connections returned by Environment.Topology:
+//
+//	{
+//	  "streams": [{"name": "invoices", "options": {"max_length_bytes": "2gb"}}],
+//	  "super_streams": [
+//	    {"name": "orders", "partitions": 3, "options": {"max_age": "72h"}},
+//	    {"name": "regions", "binding_keys": ["eu", "us"]}
+//	  ]
+//	}
+type TopologySpec struct {
+	Streams      []TopologyStream      `json:"streams"`
+	SuperStreams []TopologySuperStream `json:"super_streams"`
+}
+
+type TopologyStream struct {
+	Name    string                `json:"name"`
+	Options TopologyStreamOptions `json:"options"`
+}
+
+// TopologySuperStream has either Partitions or BindingKeys, the options are applied to all the partitions
+type TopologySuperStream struct {
+	Name        string                `json:"name"`
+	Partitions  int                   `json:"partitions"`
+	BindingKeys []string              `json:"binding_keys"`
+	Options     TopologyStreamOptions `json:"options"`
+}
+
+// TopologyStreamOptions is the JSON and YAML form of StreamOptions, the stream section of the
+// config package (config.StreamConfig) uses it as well.
+// MaxAge is in the time.ParseDuration format, ex: 72h, the sizes are in the ByteCapacity format, ex: 2gb
+type TopologyStreamOptions struct {
+	MaxAge              string            `json:"max_age" yaml:"max_age"`
+	MaxLengthBytes      string            `json:"max_length_bytes" yaml:"max_length_bytes"`
+	MaxSegmentSizeBytes string            `json:"max_segment_size_bytes" yaml:"max_segment_size_bytes"`
+	LeaderLocator       string            `json:"leader_locator" yaml:"leader_locator"` // balanced, client-local, least-leaders or random
+	InitialClusterSize  int               `json:"initial_cluster_size" yaml:"initial_cluster_size"`
+	FilterSizeBytes     int               `json:"filter_size_bytes" yaml:"filter_size_bytes"`
+	Arguments           map[string]string `json:"arguments" yaml:"arguments"`
+}
+
+// StreamOptions converts the options and validates them
+func (o TopologyStreamOptions) StreamOptions() (*StreamOptions, error) {
+	options := NewStreamOptions().
+		SetLeaderLocator(o.LeaderLocator).
+		SetInitialClusterSize(o.InitialClusterSize).
+		SetFilterSizeBytes(o.FilterSizeBytes)
+	if o.MaxAge != "" {
+		maxAge, err := time.ParseDuration(o.MaxAge)
+		if err != nil || maxAge <= 0 {
+			return nil, fmt.Errorf("max_age must be a positive duration (ex: 72h), value: %s", o.MaxAge)
+		}
+		options.SetMaxAge(maxAge)
+	}
+	if o.MaxLengthBytes != "" {
+		options.SetMaxLengthBytes(ByteCapacity{}.From(o.MaxLengthBytes))
+	}
+	if o.MaxSegmentSizeBytes != "" {
+		options.SetMaxSegmentSizeBytes(ByteCapacity{}.From(o.MaxSegmentSizeBytes))
+	}
+	for key, value := range o.Arguments {
+		options.SetArgument(key, value)
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// ParseTopology decodes the JSON topology and validates it, the unknown fields are an error
+func ParseTopology(data []byte) (*TopologySpec, error) {
+	topology := &TopologySpec{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(topology); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTopology, err)
+	}
+	if err := topology.Validate(); err != nil {
+		return nil, err
+	}
+	return topology, nil
+}
+
+// LoadTopology reads and parses a JSON topology file
+func LoadTopology(path string) (*TopologySpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTopology(data)
+}
+
+// Validate checks the names, the partitions and the options
+func (t *TopologySpec) Validate() error {
+	names := map[string]bool{}
+	checkName := func(name string) error {
+		if name == "" {
+			return fmt.Errorf("%w: the name can't be empty", ErrInvalidTopology)
+		}
+		if names[name] {
+			return fmt.Errorf("%w: %s is declared more than once", ErrInvalidTopology, name)
+		}
+		names[name] = true
+		return nil
+	}
+
+	for _, stream := range t.Streams {
+		if err := checkName(stream.Name); err != nil {
+			return err
+		}
+		if _, err := stream.Options.StreamOptions(); err != nil {
+			return fmt.Errorf("%w: stream %s: %s", ErrInvalidTopology, stream.Name, err)
+		}
+	}
+	for _, superStream := range t.SuperStreams {
+		if err := checkName(superStream.Name); err != nil {
+			return err
+		}
+		if _, err := superStream.superStreamOptions(); err != nil {
+			return fmt.Errorf("%w: super stream %s: %s", ErrInvalidTopology, superStream.Name, err)
+		}
+	}
+	// a partition can't be declared as a stream as well
+	for _, superStream := range t.SuperStreams {
+		options, _ := superStream.superStreamOptions()
+		for _, partition := range options.getPartitions(superStream.Name) {
+			if names[partition] {
+				return fmt.Errorf("%w: %s is a partition of the super stream %s",
+					ErrInvalidTopology, partition, superStream.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s TopologySuperStream) superStreamOptions() (SuperStreamOptions, error) {
+	options, err := s.Options.StreamOptions()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case s.Partitions > 0 && len(s.BindingKeys) > 0:
+		return nil, fmt.Errorf("set partitions or binding_keys, not both")
+	case s.Partitions > 0:
+		return &PartitionsOptions{
+			Partitions:          s.Partitions,
+			MaxAge:              options.MaxAge,
+			MaxLengthBytes:      options.MaxLengthBytes,
+			MaxSegmentSizeBytes: options.MaxSegmentSizeBytes,
+			LeaderLocator:       options.LeaderLocator,
+			InitialClusterSize:  options.InitialClusterSize,
+			FilterSizeBytes:     options.FilterSizeBytes,
+			Arguments:           options.Arguments,
+		}, nil
+	case len(s.BindingKeys) > 0:
+		keys := map[string]bool{}
+		for _, key := range s.BindingKeys {
+			if key == "" || keys[key] {
+				return nil, fmt.Errorf("binding keys must be unique and not empty")
+			}
+			keys[key] = true
+		}
+		return &BindingsOptions{
+			Bindings:            s.BindingKeys,
+			MaxAge:              options.MaxAge,
+			MaxLengthBytes:      options.MaxLengthBytes,
+			MaxSegmentSizeBytes: options.MaxSegmentSizeBytes,
+			LeaderLocator:       options.LeaderLocator,
+			InitialClusterSize:  options.InitialClusterSize,
+			FilterSizeBytes:     options.FilterSizeBytes,
+			Arguments:           options.Arguments,
+		}, nil
+	default:
+		return nil, fmt.Errorf("partitions or binding_keys must be set")
+	}
+}
+
+type ApplyTopologyOptions struct {
+	// Previous is the topology applied before. When set, its streams and super streams
+	// not declared anymore are deleted. The stream protocol can't list the streams,
+	// so only the ones of Previous are candidates
+	Previous *TopologySpec
+}
+
+func NewApplyTopologyOptions() *ApplyTopologyOptions {
+	return &ApplyTopologyOptions{}
+}
+
+// SetDeleteUndeclared deletes the streams and super streams of previous that are not in the applied topology
+func (o *ApplyTopologyOptions) SetDeleteUndeclared(previous *TopologySpec) *ApplyTopologyOptions {
+	o.Previous = previous
+	return o
+}
+
+// TopologyDifference is a stream or a super stream that exists with a different configuration.
+// The differences are reported, the existing streams are never changed
+type TopologyDifference struct {
+	Name string
+	// Mismatch is set when the arguments of the stream or of the partition are different
+	Mismatch *StreamConfigMismatch
+	// ExpectedPartitions and ActualPartitions are set when the partitions of the super stream are different
+	ExpectedPartitions []string
+	ActualPartitions   []string
+}
+
+func (d TopologyDifference) String() string {
+	if d.Mismatch != nil {
+		return d.Mismatch.Error()
+	}
+	return fmt.Sprintf("super stream %s partitions expected %v actual %v", d.Name, d.ExpectedPartitions, d.ActualPartitions)
+}
+
+// TopologyReport is the result of ApplyTopology, the names are sorted
+type TopologyReport struct {
+	Created     []string // streams and super streams created
+	Unchanged   []string // streams and super streams already declared with the same configuration
+	Differences []TopologyDifference
+	Deleted     []string
+}
+
+func (r *TopologyReport) HasDifferences() bool {
+	return len(r.Differences) > 0
+}
+
+func (env *Environment) ApplyTopology(topology *TopologySpec, options *ApplyTopologyOptions) (*TopologyReport, error) {
+	return env.ApplyTopologyCtx(context.Background(), topology, options)
+}
+
+// ApplyTopologyCtx creates the streams and super streams missing and reports the ones that exist with a different
+// configuration. The super stream partitions are compared with QueryPartitions.
+// The report contains what was applied before the error, if any
+func (env *Environment) ApplyTopologyCtx(ctx context.Context, topology *TopologySpec, options *ApplyTopologyOptions) (*TopologyReport, error) {
+	if topology == nil {
+		return nil, fmt.Errorf("%w: the topology can't be nil", ErrInvalidTopology)
+	}
+	if options == nil {
+		options = NewApplyTopologyOptions()
+	}
+	if err := topology.Validate(); err != nil {
+		return nil, err
+	}
+	if options.Previous != nil {
+		if err := options.Previous.Validate(); err != nil {
+			return nil, fmt.Errorf("previous topology: %w", err)
+		}
+	}
+
+	report := &TopologyReport{}
+	defer report.sort()
+	for _, stream := range topology.Streams {
+		if err := env.applyTopologyStream(ctx, stream, report); err != nil {
+			return report, err
+		}
+	}
+	for _, superStream := range topology.SuperStreams {
+		if err := env.applyTopologySuperStream(ctx, superStream, report); err != nil {
+			return report, err
+		}
+	}
+	if options.Previous != nil {
+		if err := env.deleteUndeclared(ctx, topology, options.Previous, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (env *Environment) applyTopologyStream(ctx context.Context, stream TopologyStream, report *TopologyReport) error {
+	options, _ := stream.Options.StreamOptions()
+	exists, err := env.StreamExistsCtx(ctx, stream.Name)
+	if err != nil {
+		return err
+	}
+	err = env.EnsureStreamCtx(ctx, stream.Name, options)
+	var mismatch *StreamConfigMismatch
+	switch {
+	case errors.As(err, &mismatch):
+		report.Differences = append(report.Differences, TopologyDifference{Name: stream.Name, Mismatch: mismatch})
+	case err != nil:
+		return fmt.Errorf("stream %s: %w", stream.Name, err)
+	case exists:
+		report.Unchanged = append(report.Unchanged, stream.Name)
+	default:
+		report.Created = append(report.Created, stream.Name)
+	}
+	return nil
+}
+
+func (env *Environment) applyTopologySuperStream(ctx context.Context, superStream TopologySuperStream, report *TopologyReport) error {
+	superStreamOptions, _ := superStream.superStreamOptions()
+	actual, err := env.QueryPartitionsCtx(ctx, superStream.Name)
+	if errors.Is(err, StreamDoesNotExist) || (err == nil && len(actual) == 0) {
+		if err := env.DeclareSuperStreamCtx(ctx, superStream.Name, superStreamOptions); err != nil {
+			return fmt.Errorf("super stream %s: %w", superStream.Name, err)
+		}
+		report.Created = append(report.Created, superStream.Name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("super stream %s: %w", superStream.Name, err)
+	}
+
+	expected := superStreamOptions.getPartitions(superStream.Name)
+	changed := !sameNames(expected, actual)
+	if changed {
+		report.Differences = append(report.Differences, TopologyDifference{
+			Name:               superStream.Name,
+			ExpectedPartitions: expected,
+			ActualPartitions:   actual,
+		})
+	}
+
+	// the arguments are compared only for the partitions that exist, a missing partition
+	// must not be created as a stream outside the super stream.
+	// The partitions are compared with the arguments of the super stream declaration
+	arguments, _ := superStreamOptions.getArgs()
+	existing := map[string]bool{}
+	for _, partition := range actual {
+		existing[partition] = true
+	}
+	for _, partition := range expected {
+		if !existing[partition] {
+			continue
+		}
+		err := env.ensureStreamArgumentsCtx(ctx, partition, arguments)
+		var mismatch *StreamConfigMismatch
+		if errors.As(err, &mismatch) {
+			changed = true
+			report.Differences = append(report.Differences, TopologyDifference{Name: partition, Mismatch: mismatch})
+		} else if err != nil {
+			return fmt.Errorf("super stream %s partition %s: %w", superStream.Name, partition, err)
+		}
+	}
+	if !changed {
+		report.Unchanged = append(report.Unchanged, superStream.Name)
+	}
+	return nil
+}
+
+func (env *Environment) deleteUndeclared(ctx context.Context, topology *TopologySpec, previous *TopologySpec, report *TopologyReport) error {
+	declared := map[string]bool{}
+	for _, stream := range topology.Streams {
+		declared[stream.Name] = true
+	}
+	for _, superStream := range topology.SuperStreams {
+		declared[superStream.Name] = true
+	}
+
+	for _, stream := range previous.Streams {
+		if declared[stream.Name] {
+			continue
+		}
+		err := env.DeleteStreamCtx(ctx, stream.Name)
+		if errors.Is(err, StreamDoesNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("stream %s: %w", stream.Name, err)
+		}
+		report.Deleted = append(report.Deleted, stream.Name)
+	}
+	for _, superStream := range previous.SuperStreams {
+		if declared[superStream.Name] {
+			continue
+		}
+		err := env.DeleteSuperStreamCtx(ctx, superStream.Name)
+		if errors.Is(err, StreamDoesNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("super stream %s: %w", superStream.Name, err)
+		}
+		report.Deleted = append(report.Deleted, superStream.Name)
+	}
+	return nil
+}
+
+func (r *TopologyReport) sort() {
+	sort.Strings(r.Created)
+	sort.Strings(r.Unchanged)
+	sort.Strings(r.Deleted)
+	sort.Slice(r.Differences, func(i, j int) bool {
+		return r.Differences[i].Name < r.Differences[j].Name
+	})
+}
+
+func sameNames(expected []string, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	names := map[string]bool{}
+	for _, name := range expected {
+		names[name] = true
+	}
+	for _, name := range actual {
+		if !names[name] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Apply topology", func() {
+
+	It("Parse topology", func() {
+		topology, err := ParseTopology([]byte(`{
+			"streams": [{"name": "invoices", "options": {"max_age": "72h", "max_length_bytes": "2gb"}}],
+			"super_streams": [
+				{"name": "orders", "partitions": 3, "options": {"leader_locator": "balanced"}},
+				{"name": "regions", "binding_keys": ["eu", "us"]}
+			]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(topology.Streams).To(HaveLen(1))
+		options, err := topology.Streams[0].Options.StreamOptions()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options.MaxAge).To(Equal(72 * time.Hour))
+		Expect(options.MaxLengthBytes.bytes).To(Equal(int64(2_000_000_000)))
+		Expect(topology.SuperStreams).To(HaveLen(2))
+		Expect(topology.SuperStreams[0].Partitions).To(Equal(3))
+		Expect(topology.SuperStreams[1].BindingKeys).To(Equal([]string{"eu", "us"}))
+	})
+
+	It("Invalid topology", func() {
+		for _, data := range []string{
+			`{"streams": [{"name": ""}]}`,
+			`{"streams": [{"name": "a"}, {"name": "a"}]}`,
+			`{"streams": [{"name": "a", "options": {"max_age": "3 days"}}]}`,
+			`{"streams": [{"name": "a", "options": {"max_length_bytes": "2 giga"}}]}`,
+			`{"streams": [{"name": "a", "options": {"leader_locator": "anywhere"}}]}`,
+			`{"streams": [{"name": "a", "unknown": true}]}`,
+			`{"super_streams": [{"name": "s"}]}`,
+			`{"super_streams": [{"name": "s", "partitions": 2, "binding_keys": ["a"]}]}`,
+			`{"super_streams": [{"name": "s", "binding_keys": ["a", "a"]}]}`,
+			`{"streams": [{"name": "s-0"}], "super_streams": [{"name": "s", "partitions": 2}]}`,
+		} {
+			_, err := ParseTopology([]byte(data))
+			Expect(errors.Is(err, ErrInvalidTopology)).To(BeTrue(), data)
+		}
+	})
+
+	It("Create, report the differences and delete", Label("super-stream"), func() {
+		env, err := NewEnvironment(nil)
+		Expect(err).NotTo(HaveOccurred())
+		prefix := uuid.New().String()
+		stream := prefix + "-stream"
+		superStream := prefix + "-super"
+		topology, err := ParseTopology([]byte(fmt.Sprintf(`{
+			"streams": [{"name": %q, "options": {"max_length_bytes": "1gb"}}],
+			"super_streams": [{"name": %q, "partitions": 2, "options": {"max_length_bytes": "1gb"}}]
+		}`, stream, superStream)))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = env.ApplyTopology(nil, nil)
+		Expect(errors.Is(err, ErrInvalidTopology)).To(BeTrue())
+
+		report, err := env.ApplyTopology(topology, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Created).To(Equal([]string{stream, superStream}))
+		Expect(report.HasDifferences()).To(BeFalse())
+		partitions, err := env.QueryPartitions(superStream)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(partitions).To(ConsistOf(superStream+"-0", superStream+"-1"))
+
+		report, err = env.ApplyTopology(topology, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Created).To(BeEmpty())
+		Expect(report.Unchanged).To(Equal([]string{stream, superStream}))
+
+		changed := &TopologySpec{
+			Streams: []TopologyStream{{Name: stream, Options: TopologyStreamOptions{MaxLengthBytes: "2gb"}}},
+			SuperStreams: []TopologySuperStream{{Name: superStream, Partitions: 3,
+				Options: TopologyStreamOptions{MaxLengthBytes: "1gb"}}},
+		}
+		report, err = env.ApplyTopology(changed, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Differences).To(HaveLen(2))
+		Expect(report.Differences[0].Name).To(Equal(stream))
+		Expect(report.Differences[0].Mismatch.Expected).To(HaveKeyWithValue("max-length-bytes", "2000000000"))
+		Expect(report.Differences[1].Name).To(Equal(superStream))
+		Expect(report.Differences[1].ExpectedPartitions).To(HaveLen(3))
+		Expect(report.Differences[1].ActualPartitions).To(HaveLen(2))
+		Expect(report.Differences[1].Mismatch).To(BeNil())
+		// the existing super stream is not changed
+		partitions, err = env.QueryPartitions(superStream)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(partitions).To(HaveLen(2))
+
+		report, err = env.ApplyTopology(&TopologySpec{}, NewApplyTopologyOptions().SetDeleteUndeclared(topology))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Deleted).To(Equal([]string{stream, superStream}))
+		exists, err := env.StreamExists(stream)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+		exists, err = env.StreamExists(superStream + "-0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+		Expect(env.Close()).NotTo(HaveOccurred())
+	})
+})
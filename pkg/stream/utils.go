@@ -1,6 +1,7 @@
 package stream
 
 import (
+	"context"
 	"fmt"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
 	"strings"
@@ -35,14 +36,23 @@ func waitCodeWithDefaultTimeOut(response *Response) responseError {
 	return waitCodeWithTimeOut(response, defaultSocketCallTimeout)
 }
 func waitCodeWithTimeOut(response *Response, timeout time.Duration) responseError {
+	return waitCodeWithContext(context.Background(), response, timeout, logs.NewStandardLogger())
+}
+
+// waitCodeWithContext waits for the response code until the timeout expires
+// or the context is done. In the last case the context error is returned
+func waitCodeWithContext(ctx context.Context, response *Response, timeout time.Duration, logger logs.Logger) responseError {
 	select {
 	case code := <-response.code:
 		if code.id != responseCodeOk {
 			return newResponseError(lookErrorCode(code.id), false)
 		}
 		return newResponseError(nil, false)
+	case <-ctx.Done():
+		logger.Debug("context done - waiting Code", "operation", response.commandDescription, "error", ctx.Err())
+		return newResponseError(ctx.Err(), false)
 	case <-time.After(timeout):
-		logs.LogError("timeout %d ns - waiting Code, operation: %s", timeout.Milliseconds(), response.commandDescription)
+		logger.Error("timeout - waiting Code", "timeout_ms", timeout.Milliseconds(), "operation", response.commandDescription)
 
 		return newResponseError(
 			fmt.Errorf("timeout %d ms - waiting Code, operation: %s ",
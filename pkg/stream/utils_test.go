@@ -1,6 +1,10 @@
 package stream
 
 import (
+	"context"
+
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/logs"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"sync"
@@ -43,4 +47,22 @@ var _ = Describe("Utils", func() {
 		wg.Wait()
 	})
 
+	It("Timeout calls context canceled", func() {
+		response := newResponse(lookUpCommand(commandUnitTest))
+		response.correlationid = 9
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func(res *Response) {
+			defer GinkgoRecover()
+			err := waitCodeWithContext(ctx, res, defaultSocketCallTimeout, logs.NewStandardLogger())
+			Expect(err.Err).To(MatchError(context.Canceled))
+			Expect(err.isTimeout).To(BeFalse())
+			wg.Done()
+		}(response)
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+		wg.Wait()
+	})
+
 })